@@ -0,0 +1,72 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AutocommitDMLMode determines how DML statements are
+// executed outside of explicit transactions.
+type AutocommitDMLMode int
+
+const (
+	// Transactional executes each DML statement
+	// in its own read-write transaction.
+	Transactional AutocommitDMLMode = iota
+
+	// PartitionedNonAtomic executes DML statements as
+	// Partitioned DML. Partitioned DML is not atomic and
+	// the statement may be applied more than once to some
+	// rows, but it is not bound by the mutation limit of
+	// read-write transactions. Rows affected are a lower
+	// bound of the number of modified rows.
+	//
+	// See https://cloud.google.com/spanner/docs/dml-partitioned.
+	PartitionedNonAtomic
+)
+
+func (m AutocommitDMLMode) String() string {
+	switch m {
+	case Transactional:
+		return "TRANSACTIONAL"
+	case PartitionedNonAtomic:
+		return "PARTITIONED_NON_ATOMIC"
+	}
+	return fmt.Sprintf("AutocommitDMLMode(%d)", int(m))
+}
+
+func parseAutocommitDMLMode(s string) (AutocommitDMLMode, error) {
+	switch strings.ToUpper(s) {
+	case "TRANSACTIONAL":
+		return Transactional, nil
+	case "PARTITIONED_NON_ATOMIC":
+		return PartitionedNonAtomic, nil
+	}
+	return 0, fmt.Errorf("invalid autocommit DML mode %q, want TRANSACTIONAL or PARTITIONED_NON_ATOMIC", s)
+}
+
+func (c *conn) AutocommitDMLMode() AutocommitDMLMode {
+	return c.vars.autocommitDMLMode
+}
+
+func (c *conn) SetAutocommitDMLMode(mode AutocommitDMLMode) error {
+	if mode != Transactional && mode != PartitionedNonAtomic {
+		return fmt.Errorf("invalid autocommit DML mode: %v", mode)
+	}
+	c.vars.autocommitDMLMode = mode
+	return nil
+}
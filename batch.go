@@ -0,0 +1,131 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/spanner"
+)
+
+// BatchError is returned by RunBatch and RUN BATCH
+// if a statement of the DML batch fails.
+type BatchError struct {
+	// Index is the index of the failed statement in the batch.
+	Index int
+
+	// RowsAffected contains the number of rows affected by
+	// each statement executed before the failed statement.
+	// In autocommit mode, these changes are not committed.
+	RowsAffected []int64
+
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch DML statement %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+type dmlBatch struct {
+	stmts []spanner.Statement
+}
+
+// StartBatchDML starts a DML batch on the connection. Until
+// RunBatch or AbortBatch is called, DML statements executed
+// on the connection are buffered instead of being sent to
+// Spanner. It is equivalent to executing START BATCH DML.
+func (c *conn) StartBatchDML() error {
+	if err := c.errIfReplaying("DML batches"); err != nil {
+		return err
+	}
+	if c.roTx != nil {
+		return errors.New("cannot start a DML batch in read-only transaction")
+	}
+	if c.batch != nil {
+		return errors.New("already in a DML batch")
+	}
+	if c.rwTx == nil && c.vars.autocommitDMLMode == PartitionedNonAtomic {
+		return errors.New("cannot start a DML batch in PARTITIONED_NON_ATOMIC mode")
+	}
+	c.batch = &dmlBatch{}
+	return nil
+}
+
+// RunBatch sends the buffered DML statements to Spanner in a
+// single round trip and returns the number of rows affected
+// by each statement. Outside of a read-write transaction, the
+// statements are executed in a new read-write transaction.
+// If a statement fails, the returned error is a *BatchError.
+// If the statements succeed but the new transaction fails to
+// commit, the error of the commit is returned without counts.
+// It is equivalent to executing RUN BATCH.
+func (c *conn) RunBatch(ctx context.Context) ([]int64, error) {
+	if c.batch == nil {
+		return nil, errors.New("not in a DML batch")
+	}
+	stmts := c.batch.stmts
+	c.batch = nil
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+
+	if c.rwTx == nil {
+		return c.batchUpdateInNewRWTransaction(ctx, stmts)
+	}
+	counts, err := c.rwTx.BatchUpdate(ctx, stmts, c.queryOptions(ctx))
+	if err != nil {
+		return counts, &BatchError{Index: len(counts), RowsAffected: counts, Err: err}
+	}
+	return counts, nil
+}
+
+// AbortBatch discards the buffered DML statements and ends
+// the batch. It is equivalent to executing ABORT BATCH.
+func (c *conn) AbortBatch() error {
+	if c.batch == nil {
+		return errors.New("not in a DML batch")
+	}
+	c.batch = nil
+	return nil
+}
+
+func (c *conn) batchUpdateInNewRWTransaction(ctx context.Context, stmts []spanner.Statement) ([]int64, error) {
+	var counts []int64
+	opts := c.queryOptions(ctx)
+	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		var err error
+		counts, err = tx.BatchUpdateWithOptions(ctx, stmts, opts)
+		if err != nil {
+			return &BatchError{Index: len(counts), RowsAffected: counts, Err: err}
+		}
+		return nil
+	}
+	err := c.runInNewRWTransaction(ctx, fn)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		return batchErr.RowsAffected, batchErr
+	}
+	if err != nil {
+		// The statements succeeded but nothing was committed.
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	likeTweet   = "UPDATE tweets SET likes = likes + 1 WHERE id = 1"
+	deleteTweet = "DELETE FROM tweets WHERE id = 2"
+)
+
+// newBatchConn returns a connection to a fake server that
+// has results for likeTweet and deleteTweet.
+func newBatchConn(t *testing.T) (*fakespanner.Server, *sql.Conn) {
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, likeTweet, &fakespanner.StatementResult{UpdateCount: 1})
+	s.MustPutStatementResult(t, deleteTweet, &fakespanner.StatementResult{UpdateCount: 2})
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, conn
+}
+
+// runBatch buffers stmts in a DML batch on conn and runs it.
+func runBatch(ctx context.Context, conn *sql.Conn, stmts ...string) ([]int64, error) {
+	var counts []int64
+	err := conn.Raw(func(driverConn interface{}) error {
+		c := driverConn.(spannerdriver.Conn)
+		if err := c.StartBatchDML(); err != nil {
+			return err
+		}
+		for _, stmt := range stmts {
+			if _, err := driverConn.(driver.ExecerContext).ExecContext(ctx, stmt, nil); err != nil {
+				return err
+			}
+		}
+		var err error
+		counts, err = c.RunBatch(ctx)
+		return err
+	})
+	return counts, err
+}
+
+func batchRequests(s *fakespanner.Server) []*sppb.ExecuteBatchDmlRequest {
+	var reqs []*sppb.ExecuteBatchDmlRequest
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.ExecuteBatchDmlRequest); ok {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func TestBatchDML(t *testing.T) {
+	ctx := context.Background()
+	s, conn := newBatchConn(t)
+
+	if _, err := conn.ExecContext(ctx, "START BATCH DML"); err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{likeTweet, deleteTweet} {
+		res, err := conn.ExecContext(ctx, stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, _ := res.RowsAffected(); n != 0 {
+			t.Errorf("buffered %q: rows affected: want: 0, got: %d", stmt, n)
+		}
+	}
+	if n := len(batchRequests(s)); n != 0 {
+		t.Fatalf("batch requests before RUN BATCH: want: 0, got: %d", n)
+	}
+	res, err := conn.ExecContext(ctx, "RUN BATCH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("RUN BATCH rows affected: want: 3, got: %d", n)
+	}
+	reqs := batchRequests(s)
+	if len(reqs) != 1 || len(reqs[0].Statements) != 2 {
+		t.Fatalf("batch requests: want one with 2 statements, got: %v", reqs)
+	}
+
+	counts, err := runBatch(ctx, conn, likeTweet, deleteTweet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("RunBatch: want: %v, got: %v", want, counts)
+	}
+}
+
+func TestBatchDMLStatementError(t *testing.T) {
+	ctx := context.Background()
+	s, conn := newBatchConn(t)
+	s.MustPutStatementResult(t, "DELETE FROM missing WHERE TRUE", &fakespanner.StatementResult{
+		Err: status.Error(codes.NotFound, "Table not found: missing"),
+	})
+
+	counts, err := runBatch(ctx, conn, likeTweet, "DELETE FROM missing WHERE TRUE", deleteTweet)
+	var batchErr *spannerdriver.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("RunBatch: want a *BatchError, got: %v", err)
+	}
+	if batchErr.Index != 1 {
+		t.Errorf("index: want: 1, got: %d", batchErr.Index)
+	}
+	if want := []int64{1}; !reflect.DeepEqual(batchErr.RowsAffected, want) || !reflect.DeepEqual(counts, want) {
+		t.Errorf("rows affected: want: %v, got: %v and %v", want, batchErr.RowsAffected, counts)
+	}
+	if got := spanner.ErrCode(batchErr.Err); got != codes.NotFound {
+		t.Errorf("error code: want: %v, got: %v", codes.NotFound, got)
+	}
+}
+
+func TestBatchDMLCommitError(t *testing.T) {
+	ctx := context.Background()
+	s, conn := newBatchConn(t)
+	s.PutCommitError(status.Error(codes.FailedPrecondition, "commit failed"))
+
+	counts, err := runBatch(ctx, conn, likeTweet, deleteTweet)
+	var batchErr *spannerdriver.BatchError
+	if errors.As(err, &batchErr) {
+		t.Fatalf("RunBatch: the commit failed, not statement %d", batchErr.Index)
+	}
+	if got := spanner.ErrCode(err); got != codes.FailedPrecondition {
+		t.Errorf("error code: want: %v, got: %v (%v)", codes.FailedPrecondition, got, err)
+	}
+	if counts != nil {
+		t.Errorf("counts of uncommitted statements: %v", counts)
+	}
+}
+
+func TestBatchDMLInTransaction(t *testing.T) {
+	ctx := context.Background()
+	s, conn := newBatchConn(t)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "START BATCH DML"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, likeTweet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, deleteTweet); err != nil {
+		t.Fatal(err)
+	}
+	res, err := tx.ExecContext(ctx, "RUN BATCH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 3 {
+		t.Errorf("RUN BATCH rows affected: want: 3, got: %d", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch ran in the transaction, which committed once.
+	var commits int
+	for _, req := range s.Requests() {
+		switch req := req.(type) {
+		case *sppb.ExecuteBatchDmlRequest:
+			if commits > 0 {
+				t.Error("batch ran after the commit")
+			}
+			if req.Transaction.GetSingleUse() != nil {
+				t.Error("batch ran in a single-use transaction")
+			}
+		case *sppb.CommitRequest:
+			commits++
+		}
+	}
+	if commits != 1 {
+		t.Errorf("commits: want: 1, got: %d", commits)
+	}
+}
+
+func TestAbortBatch(t *testing.T) {
+	ctx := context.Background()
+	s, conn := newBatchConn(t)
+
+	if _, err := conn.ExecContext(ctx, "START BATCH DML"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, likeTweet); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "ABORT BATCH"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, "RUN BATCH"); err == nil {
+		t.Error("RUN BATCH after ABORT BATCH: want error")
+	}
+	if n := len(batchRequests(s)); n != 0 {
+		t.Errorf("batch requests: want: 0, got: %d", n)
+	}
+
+	// The statements after the batch are executed right away.
+	res, err := conn.ExecContext(ctx, deleteTweet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 2 {
+		t.Errorf("rows affected: want: 2, got: %d", n)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// clientStmtKind is the kind of a statement that is executed
+// by the driver rather than being sent to Spanner.
+type clientStmtKind int
+
+const (
+	startBatchDMLStmt clientStmtKind = iota + 1
+	runBatchStmt
+	abortBatchStmt
+	runPartitionedQueryStmt
+	runPartitionStmt
+	setStmt
+	showVariableStmt
+	explainStmt
+	explainAnalyzeStmt
+)
+
+// clientStmt is a parsed client statement.
+type clientStmt struct {
+	kind clientStmtKind
+
+	// arg is the text that follows the keywords of
+	// the statement, e.g. the query to partition.
+	arg string
+}
+
+var clientStmtKeywords = []struct {
+	kind     clientStmtKind
+	keywords []string
+	hasArg   bool
+}{
+	{kind: startBatchDMLStmt, keywords: []string{"START", "BATCH", "DML"}},
+	{kind: runBatchStmt, keywords: []string{"RUN", "BATCH"}},
+	{kind: abortBatchStmt, keywords: []string{"ABORT", "BATCH"}},
+	{kind: runPartitionedQueryStmt, keywords: []string{"RUN", "PARTITIONED", "QUERY"}, hasArg: true},
+	{kind: runPartitionStmt, keywords: []string{"RUN", "PARTITION"}, hasArg: true},
+	{kind: setStmt, keywords: []string{"SET"}, hasArg: true},
+	{kind: showVariableStmt, keywords: []string{"SHOW", "VARIABLE"}, hasArg: true},
+	{kind: explainAnalyzeStmt, keywords: []string{"EXPLAIN", "ANALYZE"}, hasArg: true},
+	{kind: explainStmt, keywords: []string{"EXPLAIN"}, hasArg: true},
+}
+
+// parseClientStmt reports whether q is a client statement.
+// Client statement keywords are case insensitive and the
+// statement may end with a semicolon.
+func parseClientStmt(q string) (clientStmt, bool) {
+	q = strings.TrimSuffix(strings.TrimSpace(q), ";")
+	for _, s := range clientStmtKeywords {
+		arg, ok := matchKeywords(q, s.keywords)
+		if !ok || (!s.hasArg && arg != "") {
+			continue
+		}
+		return clientStmt{kind: s.kind, arg: arg}, true
+	}
+	return clientStmt{}, false
+}
+
+// matchKeywords reports whether q starts with the keywords
+// and returns the rest of q.
+func matchKeywords(q string, keywords []string) (string, bool) {
+	for _, kw := range keywords {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if len(q) < len(kw) || !strings.EqualFold(q[:len(kw)], kw) {
+			return "", false
+		}
+		q = q[len(kw):]
+		if q != "" && !unicode.IsSpace(rune(q[0])) {
+			return "", false
+		}
+	}
+	return strings.TrimSpace(q), true
+}
+
+func (c *conn) execClientStmt(ctx context.Context, s clientStmt) (driver.Result, error) {
+	switch s.kind {
+	case startBatchDMLStmt:
+		return &result{}, c.StartBatchDML()
+	case runBatchStmt:
+		counts, err := c.RunBatch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var rowsAffected int64
+		for _, n := range counts {
+			rowsAffected += n
+		}
+		return &result{rowsAffected: rowsAffected}, nil
+	case abortBatchStmt:
+		return &result{}, c.AbortBatch()
+	case setStmt:
+		return &result{}, c.execSet(s.arg)
+	case runPartitionedQueryStmt, runPartitionStmt, showVariableStmt, explainStmt, explainAnalyzeStmt:
+		return nil, errors.New("statements that return rows need to be run with QueryContext")
+	}
+	return nil, fmt.Errorf("unknown client statement: %d", s.kind)
+}
+
+func (c *conn) queryClientStmt(ctx context.Context, s clientStmt, args []driver.NamedValue) (*rows, error) {
+	switch s.kind {
+	case runPartitionedQueryStmt:
+		return c.runPartitionedQuery(ctx, s.arg, args)
+	case runPartitionStmt:
+		return c.runPartition(ctx, s.arg)
+	case showVariableStmt:
+		return c.queryShow(s.arg)
+	case explainStmt:
+		return c.explain(ctx, s.arg, args, false)
+	case explainAnalyzeStmt:
+		return c.explain(ctx, s.arg, args, true)
+	case startBatchDMLStmt, runBatchStmt, abortBatchStmt, setStmt:
+		return nil, errors.New("statements that do not return rows need to be run with ExecContext")
+	}
+	return nil, fmt.Errorf("unknown client statement: %d", s.kind)
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import "testing"
+
+func TestParseClientStmt(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   clientStmt
+		wantOk bool
+	}{
+		{input: "START BATCH DML", want: clientStmt{kind: startBatchDMLStmt}, wantOk: true},
+		{input: "  start   batch\ndml ; ", want: clientStmt{kind: startBatchDMLStmt}, wantOk: true},
+		{input: "RUN BATCH", want: clientStmt{kind: runBatchStmt}, wantOk: true},
+		{input: "ABORT BATCH;", want: clientStmt{kind: abortBatchStmt}, wantOk: true},
+		{input: "RUN BATCH NOW"},
+		{input: "RUN BATCHES"},
+		{
+			input:  "RUN PARTITIONED QUERY SELECT * FROM tweets WHERE likes > @likes",
+			want:   clientStmt{kind: runPartitionedQueryStmt, arg: "SELECT * FROM tweets WHERE likes > @likes"},
+			wantOk: true,
+		},
+		{
+			input:  "run partition 'abc'",
+			want:   clientStmt{kind: runPartitionStmt, arg: "'abc'"},
+			wantOk: true,
+		},
+		{
+			input:  "SET READ_ONLY_STALENESS = 'MAX_STALENESS 10s';",
+			want:   clientStmt{kind: setStmt, arg: "READ_ONLY_STALENESS = 'MAX_STALENESS 10s'"},
+			wantOk: true,
+		},
+		{
+			input:  "show variable commit_timestamp",
+			want:   clientStmt{kind: showVariableStmt, arg: "commit_timestamp"},
+			wantOk: true,
+		},
+		{input: "SHOW VARIABLES"},
+		{
+			input:  "EXPLAIN SELECT * FROM tweets",
+			want:   clientStmt{kind: explainStmt, arg: "SELECT * FROM tweets"},
+			wantOk: true,
+		},
+		{
+			input:  "explain analyze SELECT * FROM tweets;",
+			want:   clientStmt{kind: explainAnalyzeStmt, arg: "SELECT * FROM tweets"},
+			wantOk: true,
+		},
+		{input: "SELECT * FROM tweets"},
+		{input: ""},
+	}
+
+	for _, tc := range tests {
+		got, ok := parseClientStmt(tc.input)
+		if ok != tc.wantOk {
+			t.Errorf("%q: want ok: %v, got: %v", tc.input, tc.wantOk, ok)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: want: %+v, got: %+v", tc.input, tc.want, got)
+		}
+	}
+}
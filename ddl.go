@@ -0,0 +1,65 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc"
+)
+
+// execDDL updates the schema of the database and waits
+// until the schema change is complete.
+func (c *conn) execDDL(ctx context.Context, query string, args []driver.NamedValue) error {
+	if c.inTransaction() {
+		return errors.New("DDL statements cannot be executed in transactions")
+	}
+	if c.batch != nil {
+		return errors.New("DDL statements cannot be executed in a DML batch")
+	}
+	if len(args) > 0 {
+		return errors.New("DDL statements cannot have parameters")
+	}
+	if c.adminClient == nil {
+		opts := append([]option.ClientOption{}, c.opts...)
+		// Unlike the Spanner client, the admin client
+		// doesn't connect to the emulator by itself.
+		if host, ok := os.LookupEnv("SPANNER_EMULATOR_HOST"); ok {
+			opts = append(opts,
+				option.WithEndpoint(host),
+				option.WithoutAuthentication(),
+				option.WithGRPCDialOption(grpc.WithInsecure()))
+		}
+		adminClient, err := adminapi.NewDatabaseAdminClient(ctx, opts...)
+		if err != nil {
+			return err
+		}
+		c.adminClient = adminClient
+	}
+	op, err := c.adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   c.database,
+		Statements: []string{query},
+	})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// requestTypes returns the types of the requests received by s.
+func requestTypes(s *fakespanner.Server) []string {
+	var types []string
+	for _, req := range s.Requests() {
+		types = append(types, string(proto.MessageName(req).Name()))
+	}
+	return types
+}
+
+func TestDispatchDDL(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+
+	stmts := []string{
+		"CREATE TABLE tweets (id INT64 NOT NULL, text STRING(MAX)) PRIMARY KEY (id)",
+		"/* index */ create index tweets_by_text on tweets (text)",
+		"DROP INDEX tweets_by_text",
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("%q: %v", stmt, err)
+		}
+	}
+	var got []string
+	for _, req := range s.Requests() {
+		req, ok := req.(*adminpb.UpdateDatabaseDdlRequest)
+		if !ok {
+			t.Errorf("DDL sent to the Spanner API: %v", req)
+			continue
+		}
+		if req.Database != fakespanner.Database {
+			t.Errorf("database: want: %q, got: %q", fakespanner.Database, req.Database)
+		}
+		got = append(got, req.Statements...)
+	}
+	if !reflect.DeepEqual(got, stmts) {
+		t.Errorf("schema updates:\nwant: %q\ngot:  %q", stmts, got)
+	}
+
+	// DDL statements don't take parameters and
+	// can't run in transactions.
+	s.ClearRequests()
+	if _, err := db.ExecContext(ctx, "DROP TABLE tweets", 1); err == nil {
+		t.Error("DDL with parameters: want error")
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DROP TABLE tweets"); err == nil {
+		t.Error("DDL in a transaction: want error")
+	}
+	for _, req := range s.Requests() {
+		if _, ok := req.(*adminpb.UpdateDatabaseDdlRequest); ok {
+			t.Errorf("rejected DDL was sent: %v", req)
+		}
+	}
+}
+
+func TestDispatchUnknownStatements(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	const (
+		exec  = "REPLACE INTO tweets (id, text) VALUES (1, 'hello')"
+		query = "FROM tweets |> WHERE likes > 10 |> SELECT text"
+	)
+	s.MustPutStatementResult(t, exec, &fakespanner.StatementResult{UpdateCount: 1})
+	s.MustPutStatementResult(t, query, &fakespanner.StatementResult{
+		Columns: []string{"text"},
+		Rows:    [][]interface{}{{"hello"}},
+	})
+
+	// Unknown statements run with ExecContext are
+	// sent as DML, in a read-write transaction.
+	res, err := db.ExecContext(ctx, exec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("rows affected: want: 1, got: %d", n)
+	}
+	var dml *sppb.ExecuteSqlRequest
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.ExecuteSqlRequest); ok && req.Sql == exec {
+			dml = req
+		}
+	}
+	if dml == nil || dml.Transaction.GetSingleUse() != nil {
+		t.Errorf("unknown statement wasn't executed as DML: %v", requestTypes(s))
+	}
+
+	// Unknown statements run with QueryContext are
+	// sent as queries, in a single-use transaction.
+	s.ClearRequests()
+	var text string
+	if err := db.QueryRowContext(ctx, query).Scan(&text); err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello" {
+		t.Errorf("text: want: hello, got: %q", text)
+	}
+	var q *sppb.ExecuteSqlRequest
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.ExecuteSqlRequest); ok && req.Sql == query {
+			q = req
+		}
+	}
+	if q == nil || q.Transaction.GetSingleUse() == nil {
+		t.Errorf("unknown statement wasn't executed as a query: %v", requestTypes(s))
+	}
+}
+
+func TestDispatchRejected(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+
+	for _, stmt := range []string{
+		"UPDATE tweets SET likes = likes + 1 WHERE TRUE",
+		"DELETE FROM tweets WHERE TRUE",
+		"CREATE TABLE tweets (id INT64 NOT NULL) PRIMARY KEY (id)",
+		"ALTER TABLE tweets ADD COLUMN text STRING(MAX)",
+	} {
+		if _, err := db.QueryContext(ctx, stmt); err == nil {
+			t.Errorf("QueryContext(%q): want error", stmt)
+		}
+	}
+	if _, err := db.ExecContext(ctx, "SELECT text FROM tweets"); err == nil {
+		t.Error("ExecContext of a query: want error")
+	}
+	if types := requestTypes(s); len(types) != 0 {
+		t.Errorf("rejected statements sent requests: %v", types)
+	}
+}
@@ -19,17 +19,27 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"time"
 
 	"cloud.google.com/go/spanner"
+	adminapi "cloud.google.com/go/spanner/admin/database/apiv1"
 	"github.com/rakyll/go-sql-driver-spanner/internal"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 )
 
 const userAgent = "go-sql-driver-spanner/0.1"
 
 var _ driver.DriverContext = &Driver{}
 
+// ErrAbortedTransaction is returned if Spanner aborts a read-write
+// transaction and the transaction cannot be retried by the driver,
+// either because RETRY_ABORTS_INTERNALLY is disabled or because the
+// results of the transaction have already been returned.
+var ErrAbortedTransaction = internal.ErrAbortedTransaction
+
 func init() {
 	sql.Register("spanner", &Driver{})
 }
@@ -43,52 +53,225 @@ type Driver struct {
 	// Options represent the optional Google Cloud client options
 	// to be passed to the underlying client.
 	Options []option.ClientOption
+
+	// StatementTimeout is the default statement timeout of the
+	// connections. It can be overridden in the data source name
+	// and with SET STATEMENT_TIMEOUT. Zero means no timeout.
+	StatementTimeout time.Duration
+
+	// TracerProvider provides the tracer of the OpenTelemetry spans
+	// of the driver operations. If nil, the global tracer provider
+	// is used.
+	TracerProvider trace.TracerProvider
+
+	// RedactStatements removes the string and number literals
+	// from the statements recorded by the driver, and the values
+	// of the parameters from the logs.
+	RedactStatements bool
+
+	// Metrics receives the measurements of the driver, if set.
+	Metrics Metrics
+
+	// Logger logs the statements executed by the driver, if set.
+	Logger Logger
+
+	// SlowStatementThreshold is the duration after which statements
+	// are logged as slow, with WarnContext. Zero disables it.
+	SlowStatementThreshold time.Duration
+
+	// Interceptors are called around the operations of the
+	// connections, in order.
+	Interceptors []Interceptor
+
+	// Recording records the statements executed with the driver
+	// to a golden file, or replays them from the file, if set.
+	Recording *Recording
+
+	// Faults injects errors and delays into the operations
+	// of the driver, if set. It is meant for tests only.
+	Faults *Faults
 }
 
 // Open opens a connection to a Google Cloud Spanner database.
 // Use fully qualified string:
 //
 // Example: projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE
+//
+// Connection variables can be appended to the name, separated
+// by semicolons:
+//
+// Example: projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE;autocommitDMLMode=PARTITIONED_NON_ATOMIC
 func (d *Driver) Open(name string) (driver.Conn, error) {
-	return openDriverConn(context.Background(), d, name)
+	c, err := d.OpenConnector(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
 }
 
 func (d *Driver) OpenConnector(name string) (driver.Connector, error) {
+	rec, err := newRecorder(d.Recording)
+	if err != nil {
+		return nil, err
+	}
 	return &connector{
 		driver: d,
 		name:   name,
+		rec:    rec,
+		faults: newFaultInjector(d.Faults),
 	}, nil
 }
 
 type connector struct {
 	driver *Driver
 	name   string
+
+	// rec records or replays the statements
+	// of the connections, if set.
+	rec *recorder
+
+	// faults injects the faults of the
+	// connections, if set.
+	faults *faultInjector
 }
 
 func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
-	return openDriverConn(ctx, c.driver, c.name)
+	return openDriverConn(ctx, c)
 }
 
-func openDriverConn(ctx context.Context, d *Driver, name string) (driver.Conn, error) {
+func openDriverConn(ctx context.Context, c *connector) (_ driver.Conn, err error) {
+	d, name, rec := c.driver, c.name, c.rec
 	if d.Config.NumChannels == 0 {
 		d.Config.NumChannels = 1 // TODO(jbd): Explain database/sql has a high-level management.
 	}
-	opts := append(d.Options, option.WithUserAgent(userAgent))
-	client, err := spanner.NewClientWithConfig(ctx, name, d.Config, opts...)
+	defaults := defaultConnOptions()
+	defaults.statementTimeout = d.StatementTimeout
+	database, connOpts, err := parseDSN(name, defaults)
 	if err != nil {
 		return nil, err
 	}
-	return &conn{client: client}, nil
+	inst := newInstrumentation(d, database)
+	ctx, op := inst.start(ctx, "Open")
+	defer func() { op.end(err) }()
+	var statsDriver *Driver
+	if d.Metrics != nil {
+		if err := sessionStats.register(d); err != nil {
+			return nil, err
+		}
+		statsDriver = d
+	}
+
+	opts := append(d.Options, option.WithUserAgent(userAgent))
+	var client *spanner.Client
+	if !rec.replaying() {
+		client, err = spanner.NewClientWithConfig(ctx, database, d.Config, opts...)
+		if err != nil {
+			if statsDriver != nil {
+				sessionStats.unregister(statsDriver)
+			}
+			return nil, err
+		}
+	}
+	inst.count(ctx, MetricConnectionsOpen, 1)
+	return &conn{
+		database:     database,
+		opts:         opts,
+		client:       client,
+		inst:         inst,
+		defaults:     connOpts,
+		interceptors: d.Interceptors,
+		rec:          rec,
+		faults:       c.faults,
+		statsDriver:  statsDriver,
+		vars:         connOpts,
+	}, nil
 }
 
 func (c *connector) Driver() driver.Driver {
-	return &Driver{}
+	return c.driver
+}
+
+// Conn is the interface implemented by the connections of the driver.
+// It is accessible through sql.Conn.Raw:
+//
+//	conn.Raw(func(driverConn interface{}) error {
+//		return driverConn.(spannerdriver.Conn).Apply(ctx, ms)
+//	})
+type Conn interface {
+	// Apply writes the mutations. If the connection is in a
+	// read-write transaction, the mutations are buffered and
+	// applied when the transaction commits. Otherwise, they
+	// are applied atomically in a new read-write transaction.
+	Apply(ctx context.Context, ms []*spanner.Mutation) error
+
+	// StartBatchDML starts buffering the DML statements
+	// executed on the connection.
+	StartBatchDML() error
+
+	// RunBatch executes the buffered DML statements and
+	// returns the rows affected by each of them.
+	RunBatch(ctx context.Context) ([]int64, error)
+
+	// AbortBatch discards the buffered DML statements.
+	AbortBatch() error
+
+	// AutocommitDMLMode returns the mode DML statements are
+	// executed in outside of explicit transactions.
+	AutocommitDMLMode() AutocommitDMLMode
+
+	// SetAutocommitDMLMode sets the mode DML statements are
+	// executed in outside of explicit transactions.
+	SetAutocommitDMLMode(mode AutocommitDMLMode) error
+
+	// PartitionQuery splits the query into partitions that
+	// can be executed in parallel with ExecutePartition. It
+	// reads with the READ_ONLY_STALENESS of the connection
+	// and fails in a transaction.
+	PartitionQuery(ctx context.Context, query string, args ...interface{}) (*PartitionedQuery, error)
 }
 
+var (
+	_ Conn                   = &conn{}
+	_ driver.QueryerContext  = &conn{}
+	_ driver.ExecerContext   = &conn{}
+	_ driver.SessionResetter = &conn{}
+)
+
 type conn struct {
-	client *spanner.Client
-	roTx   *spanner.ReadOnlyTransaction
-	rwTx   *rwTx
+	database string
+	opts     []option.ClientOption
+
+	client      *spanner.Client
+	adminClient *adminapi.DatabaseAdminClient // lazily created by execDDL
+
+	inst         *instrumentation
+	interceptors interceptors
+	rec          *recorder
+	faults       *faultInjector
+
+	// statsDriver is the driver the connection registered
+	// with sessionStats, if any.
+	statsDriver *Driver
+
+	roTx  *spanner.ReadOnlyTransaction
+	rwTx  *rwTx
+	batch *dmlBatch
+
+	// inReplayTx is set in the transactions of replay mode.
+	inReplayTx bool
+
+	// defaults are the connection variables set in the data
+	// source name, vars are their current values.
+	defaults connOptions
+	vars     connOptions
+
+	// commitTimestamp and readTimestamp are the timestamps of
+	// the last read-write and read-only transactions.
+	commitTimestamp time.Time
+	readTimestamp   time.Time
+
+	// txPriority is the priority of the current transaction.
+	txPriority Priority
 }
 
 func (c *conn) Prepare(query string) (driver.Stmt, error) {
@@ -96,15 +279,62 @@ func (c *conn) Prepare(query string) (driver.Stmt, error) {
 }
 
 func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
-	// TODO(jbd): Mention emails need to be escaped.
-	args, err := internal.NamedValueParamNames(query, -1)
+	p, err := parseStatement(query)
 	if err != nil {
 		return nil, err
 	}
-	return &stmt{conn: c, query: query, numArgs: len(args)}, nil
+	return &stmt{conn: c, query: query, numArgs: len(p.names)}, nil
 }
 
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if ms, ok := mutationsArg(args); ok {
+		return c.applyMutations(ctx, ms)
+	}
+	ctx, op := c.inst.startStatement(ctx, "ExecContext", query, args, c.transactionType())
+	d := newStatementDeadline(ctx, c.vars.statementTimeout)
+	defer d.done()
+	// Injected faults are checked before the statement
+	// is recorded, so that they aren't recorded.
+	err := c.faults.exec(query)
+	var res driver.Result
+	if err == nil {
+		res, err = c.rec.exec(query, args, func() (driver.Result, error) {
+			return c.exec(d.ctx, query, args)
+		})
+	}
+	err = d.err(err)
+	if err == nil {
+		if n, err := res.RowsAffected(); err == nil {
+			op.setRowsAffected(n)
+		}
+	}
+	op.end(err)
+	return res, err
+}
+
+func (c *conn) exec(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	p, err := parseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+	if p.client != nil {
+		return c.execClientStmt(ctx, *p.client)
+	}
+	switch p.kind {
+	case internal.QueryStatement:
+		return nil, errors.New("queries need to be run with QueryContext")
+	case internal.DDLStatement:
+		if err := c.execDDL(ctx, query, args); err != nil {
+			return nil, err
+		}
+		return &result{}, nil
+	}
+	// Unknown statements are sent as DML and
+	// Spanner reports if they are invalid.
+	return c.execDML(ctx, query, args)
+}
+
+func (c *conn) execDML(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	if c.roTx != nil {
 		return nil, errors.New("cannot write in read-only transaction")
 	}
@@ -112,21 +342,111 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 	if err != nil {
 		return nil, err
 	}
+	if err := c.interceptors.beforeExec(ctx, &ss); err != nil {
+		return nil, err
+	}
+	rowsAffected, err := c.execStmt(ctx, ss)
+	c.interceptors.afterExec(ctx, ss, rowsAffected, err)
+	if err != nil {
+		return nil, err
+	}
+	return &result{rowsAffected: rowsAffected}, nil
+}
 
-	var rowsAffected int64
+func (c *conn) execStmt(ctx context.Context, ss spanner.Statement) (int64, error) {
+	if c.batch != nil {
+		// Rows affected are reported when the batch runs.
+		c.batch.stmts = append(c.batch.stmts, ss)
+		return 0, nil
+	}
+	if c.rwTx == nil && c.vars.autocommitDMLMode == PartitionedNonAtomic {
+		return c.client.PartitionedUpdateWithOptions(ctx, ss, c.queryOptions(ctx))
+	}
 	if c.rwTx == nil {
-		rowsAffected, err = c.execContextInNewRWTransaction(ctx, ss)
-	} else {
-		rowsAffected, err = c.rwTx.ExecContext(ctx, ss)
+		return c.execContextInNewRWTransaction(ctx, ss)
+	}
+	return c.rwTx.ExecContext(ctx, ss, c.queryOptions(ctx))
+}
+
+// QueryContext executes a query. The statement timeout applies
+// until the returned rows are closed.
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	ctx, op := c.inst.startStatement(ctx, "QueryContext", query, args, c.transactionType())
+	d := newStatementDeadline(ctx, c.vars.statementTimeout)
+	err := c.faults.query(query)
+	var r *rows
+	if err == nil {
+		r, err = c.rec.query(ctx, query, args, func() (*rows, error) {
+			return c.query(d.ctx, query, args)
+		})
 	}
 	if err != nil {
+		d.done()
+		err = d.err(err)
+		op.end(err)
 		return nil, err
 	}
-	return &result{rowsAffected: rowsAffected}, nil
+	r.deadline = d
+	r.op = op
+	r.faults = c.faults
+	return r, nil
+}
+
+func (c *conn) query(ctx context.Context, query string, args []driver.NamedValue) (*rows, error) {
+	p, err := parseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+	if p.client != nil {
+		return c.queryClientStmt(ctx, *p.client, args)
+	}
+	switch p.kind {
+	case internal.DMLReturningStatement:
+		return c.queryDMLReturning(ctx, query, args)
+	case internal.DMLStatement:
+		return nil, errors.New("DML statements without THEN RETURN need to be run with ExecContext")
+	case internal.DDLStatement:
+		return nil, errors.New("DDL statements need to be run with ExecContext")
+	}
+	// Unknown statements are sent as queries and
+	// Spanner reports if they are invalid.
+	ss, err := c.prepareQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := c.queryOptions(ctx)
+	if queryProfileFromContext(ctx) {
+		opts.Mode = queryMode(sppb.ExecuteSqlRequest_PROFILE)
+	}
+	r := &rows{it: c.runQuery(ctx, ss, opts), stats: resultStatsFromContext(ctx)}
+	return c.interceptRows(ctx, ss, r, nil)
+}
+
+// runQuery runs a query in the current transaction of
+// the connection, or in a single-use transaction.
+func (c *conn) runQuery(ctx context.Context, ss spanner.Statement, opts spanner.QueryOptions) *spanner.RowIterator {
+	if c.roTx != nil {
+		return c.roTx.QueryWithOptions(ctx, ss, opts)
+	}
+	if c.rwTx != nil {
+		return c.rwTx.Query(ctx, ss, opts)
+	}
+	return c.client.Single().WithTimestampBound(c.vars.readOnlyStaleness.bound).QueryWithOptions(ctx, ss, opts)
 }
 
 func (c *conn) Close() error {
+	c.inst.count(context.Background(), MetricConnectionsOpen, -1)
+	if c.statsDriver != nil {
+		sessionStats.unregister(c.statsDriver)
+	}
+	if c.client == nil {
+		return nil // replaying
+	}
 	c.client.Close()
+	if c.adminClient != nil {
+		return c.adminClient.Close()
+	}
 	return nil
 }
 
@@ -135,23 +455,83 @@ func (c *conn) Begin() (driver.Tx, error) {
 }
 
 func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	txType := transactionTypeKey.String("read_write")
+	if opts.ReadOnly {
+		txType = transactionTypeKey.String("read_only")
+	}
+	_, op := c.inst.start(ctx, "BeginTx", txType)
+	if err := c.interceptors.beforeBegin(ctx, opts); err != nil {
+		op.end(err)
+		return nil, err
+	}
+	tx, err := c.beginTx(ctx, opts)
+	c.interceptors.afterBegin(ctx, opts, err)
+	op.end(err)
+	return tx, err
+}
+
+func (c *conn) beginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
 	if c.inTransaction() {
 		return nil, errors.New("already in a transaction")
 	}
+	if c.batch != nil {
+		return nil, errors.New("cannot begin transaction in a DML batch")
+	}
+	if c.rec.replaying() {
+		c.inReplayTx = true
+		return &replayTx{close: func() {
+			c.inReplayTx = false
+			c.batch = nil // discard unfinished batches
+		}}, nil
+	}
 
 	if opts.ReadOnly {
-		c.roTx = c.client.ReadOnlyTransaction().WithTimestampBound(spanner.StrongRead())
-		return &roTx{close: func() {
-			c.roTx.Close()
-			c.roTx = nil
-		}}, nil
+		s := c.vars.readOnlyStaleness
+		if s.singleUse {
+			return nil, fmt.Errorf("read-only staleness %s can only be used outside of transactions", s.text)
+		}
+		c.roTx = c.client.ReadOnlyTransaction().WithTimestampBound(s.bound)
+		c.txPriority = c.priority(ctx)
+		c.inst.count(ctx, MetricTransactionsActive, 1)
+		return &roTx{
+			ctx:          ctx,
+			inst:         c.inst,
+			interceptors: c.interceptors,
+			close: func() {
+				if ts, err := c.roTx.Timestamp(); err == nil {
+					c.readTimestamp = ts
+				}
+				c.roTx.Close()
+				c.roTx = nil
+				c.txPriority = PriorityUnspecified
+				c.inst.count(ctx, MetricTransactionsActive, -1)
+			},
+		}, nil
 	}
 
-	connector := internal.NewRWConnector(ctx, c.client)
+	// The transaction has its own context, so that the commit
+	// can be canceled when it exceeds the statement timeout.
+	txCtx, cancel := context.WithCancel(ctx)
+	c.txPriority = c.priority(ctx)
+	connector := internal.NewRWConnector(txCtx, c.client, c.transactionOptions(ctx))
+	c.inst.count(ctx, MetricTransactionsActive, 1)
 	c.rwTx = &rwTx{
-		connector: connector,
+		ctx:          ctx,
+		inst:         c.inst,
+		interceptors: c.interceptors,
+		faults:       c.faults,
+		connector:    connector,
+		cancel:       cancel,
+		timeout:      func() time.Duration { return c.vars.statementTimeout },
 		close: func() {
+			cancel()
+			if !connector.CommitTimestamp.IsZero() {
+				c.commitTimestamp = connector.CommitTimestamp
+			}
 			c.rwTx = nil
+			c.txPriority = PriorityUnspecified
+			c.batch = nil // discard unfinished batches
+			c.inst.count(ctx, MetricTransactionsActive, -1)
 		},
 	}
 
@@ -161,24 +541,59 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 	case <-connector.Ready:
 		return c.rwTx, nil
 	case err := <-connector.Errors: // If received before Ready, transaction failed to start.
+		c.rwTx.close()
 		return nil, err
 	case <-time.Tick(10 * time.Second):
+		c.rwTx.close()
 		return nil, errors.New("cannot begin transaction, timeout after 10 seconds")
 	}
 }
 
 func (c *conn) inTransaction() bool {
-	return c.roTx != nil || c.rwTx != nil
+	return c.roTx != nil || c.rwTx != nil || c.inReplayTx
+}
+
+// runInNewRWTransaction runs fn in a new read-write transaction
+// and records its commit timestamp. If RETRY_ABORTS_INTERNALLY is
+// disabled, ErrAbortedTransaction is returned instead of retrying
+// fn when Spanner aborts the transaction. Explicit transactions
+// don't run here and are never retried, see NewRWConnector.
+func (c *conn) runInNewRWTransaction(ctx context.Context, fn func(ctx context.Context, tx *spanner.ReadWriteTransaction) error) error {
+	var attempts int
+	resp, err := c.client.ReadWriteTransactionWithOptions(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		attempts++
+		if attempts > 1 && !c.vars.retryAbortsInternally {
+			return ErrAbortedTransaction
+		}
+		if err := fn(ctx, tx); err != nil {
+			return err
+		}
+		return c.faults.commit()
+	}, c.transactionOptions(ctx))
+	trace.SpanFromContext(ctx).SetAttributes(attemptsKey.Int(attempts))
+	if attempts > 1 {
+		// Each attempt after the first one follows an abort.
+		c.inst.count(ctx, MetricTransactionsAborted, int64(attempts-1))
+		if c.vars.retryAbortsInternally {
+			c.inst.count(ctx, MetricTransactionRetries, int64(attempts-1))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	c.commitTimestamp = resp.CommitTs
+	return nil
 }
 
 func (c *conn) execContextInNewRWTransaction(ctx context.Context, statement spanner.Statement) (int64, error) {
 	var rowsAffected int64
+	opts := c.queryOptions(ctx)
 	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
-		count, err := tx.Update(ctx, statement)
+		count, err := tx.UpdateWithOptions(ctx, statement, opts)
 		rowsAffected = count
 		return err
 	}
-	_, err := c.client.ReadWriteTransaction(ctx, fn)
+	err := c.runInNewRWTransaction(ctx, fn)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,50 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseDSN parses a data source name in the form of
+//
+//	projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE;key1=value1;key2=value2
+//
+// and returns the database name and the connection options, which
+// override the given defaults. The options are connection variables,
+// e.g. readOnlyStaleness or READ_ONLY_STALENESS. Option keys are
+// case insensitive.
+func parseDSN(dsn string, defaults connOptions) (string, connOptions, error) {
+	opts := defaults
+	parts := strings.Split(dsn, ";")
+	for _, p := range parts[1:] {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 {
+			return "", opts, fmt.Errorf("invalid option %q in data source name, want key=value", p)
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if _, _, ok := lookupConnVar(key); !ok {
+			return "", opts, fmt.Errorf("unknown option %q in data source name", key)
+		}
+		if err := setConnVar(&opts, key, value); err != nil {
+			return "", opts, err
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestParseDSN(t *testing.T) {
+	const database = "projects/p/instances/i/databases/d"
+	withOpts := func(f func(o *connOptions)) connOptions {
+		o := defaultConnOptions()
+		f(&o)
+		return o
+	}
+	tests := []struct {
+		name     string
+		input    string
+		wantOpts connOptions
+		wantErr  bool
+	}{
+		{
+			name:     "no options",
+			input:    database,
+			wantOpts: defaultConnOptions(),
+		},
+		{
+			name:     "trailing semicolon",
+			input:    database + ";",
+			wantOpts: defaultConnOptions(),
+		},
+		{
+			name:     "autocommit DML mode",
+			input:    database + ";autocommitDMLMode=PARTITIONED_NON_ATOMIC",
+			wantOpts: withOpts(func(o *connOptions) { o.autocommitDMLMode = PartitionedNonAtomic }),
+		},
+		{
+			name:     "case insensitive",
+			input:    database + "; AutocommitDmlMode = partitioned_non_atomic",
+			wantOpts: withOpts(func(o *connOptions) { o.autocommitDMLMode = PartitionedNonAtomic }),
+		},
+		{
+			name:  "read-only staleness",
+			input: database + ";readOnlyStaleness=EXACT_STALENESS 10s",
+			wantOpts: withOpts(func(o *connOptions) {
+				o.readOnlyStaleness = staleness{bound: spanner.ExactStaleness(10 * time.Second), text: "EXACT_STALENESS 10s"}
+			}),
+		},
+		{
+			name:     "underscores",
+			input:    database + ";RETRY_ABORTS_INTERNALLY=false",
+			wantOpts: withOpts(func(o *connOptions) { o.retryAbortsInternally = false }),
+		},
+		{
+			name:     "statement timeout",
+			input:    database + ";statementTimeout=5s",
+			wantOpts: withOpts(func(o *connOptions) { o.statementTimeout = 5 * time.Second }),
+		},
+		{
+			name:     "rpc priority",
+			input:    database + ";rpcPriority=low",
+			wantOpts: withOpts(func(o *connOptions) { o.rpcPriority = PriorityLow }),
+		},
+		{
+			name:    "invalid rpc priority",
+			input:   database + ";rpcPriority=URGENT",
+			wantErr: true,
+		},
+		{
+			name:    "invalid statement timeout",
+			input:   database + ";statementTimeout=5",
+			wantErr: true,
+		},
+		{
+			name:    "read-only variable",
+			input:   database + ";commitTimestamp=2020-03-01T10:00:00Z",
+			wantErr: true,
+		},
+		{
+			name:    "invalid autocommit DML mode",
+			input:   database + ";autocommitDMLMode=ATOMIC",
+			wantErr: true,
+		},
+		{
+			name:    "unknown option",
+			input:   database + ";foo=bar",
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			input:   database + ";autocommitDMLMode",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		got, gotOpts, err := parseDSN(tc.input, defaultConnOptions())
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if got != database {
+			t.Errorf("%s: want database: %q, got: %q", tc.name, database, got)
+		}
+		if !reflect.DeepEqual(tc.wantOpts, gotOpts) {
+			t.Errorf("%s: want options: %+v, got: %+v", tc.name, tc.wantOpts, gotOpts)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+	"google.golang.org/api/iterator"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+type queryProfileKey struct{}
+
+// WithQueryProfile returns a context that runs the queries executed
+// with it in PROFILE mode. Spanner returns the plan and the execution
+// statistics of the query with its results, and they are available in
+// the ResultStats of the query once all the rows have been read.
+//
+// Example:
+//
+//	var stats spannerdriver.ResultStats
+//	ctx = spannerdriver.WithResultStats(spannerdriver.WithQueryProfile(ctx), &stats)
+//	rows, err := db.QueryContext(ctx, "SELECT id, text FROM tweets")
+//	...
+//	fmt.Println(stats.QueryStats["elapsed_time"])
+func WithQueryProfile(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryProfileKey{}, true)
+}
+
+func queryProfileFromContext(ctx context.Context) bool {
+	profile, _ := ctx.Value(queryProfileKey{}).(bool)
+	return profile
+}
+
+func queryMode(m sppb.ExecuteSqlRequest_QueryMode) *sppb.ExecuteSqlRequest_QueryMode {
+	return &m
+}
+
+// explain runs EXPLAIN and EXPLAIN ANALYZE statements. EXPLAIN
+// returns the plan of the query without running it. EXPLAIN ANALYZE
+// runs the query, discards its rows, and returns the plan with the
+// execution statistics of each plan node.
+func (c *conn) explain(ctx context.Context, query string, args []driver.NamedValue, analyze bool) (*rows, error) {
+	if query == "" {
+		return nil, errors.New("missing query in EXPLAIN")
+	}
+	if err := c.errIfReplaying("EXPLAIN statements"); err != nil {
+		return nil, err
+	}
+	kind, err := internal.Classify(query)
+	if err != nil {
+		return nil, err
+	}
+	if kind != internal.QueryStatement {
+		return nil, fmt.Errorf("EXPLAIN supports queries only, got %v", kind)
+	}
+	ss, err := c.prepareQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.explainStmt(ctx, ss, analyze)
+	return c.interceptRows(ctx, ss, r, err)
+}
+
+func (c *conn) explainStmt(ctx context.Context, ss spanner.Statement, analyze bool) (*rows, error) {
+	opts := c.queryOptions(ctx)
+	opts.Mode = queryMode(sppb.ExecuteSqlRequest_PLAN)
+	if analyze {
+		opts.Mode = queryMode(sppb.ExecuteSqlRequest_PROFILE)
+	}
+
+	it := c.runQuery(ctx, ss, opts)
+	defer it.Stop()
+	for {
+		_, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if it.QueryPlan == nil {
+		return nil, errors.New("spanner returned no query plan")
+	}
+	if stats := resultStatsFromContext(ctx); stats != nil {
+		stats.QueryPlan = it.QueryPlan
+		stats.QueryStats = it.QueryStats
+	}
+	planRows, err := queryPlanRows(it.QueryPlan, analyze)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: &bufferedIterator{rows: planRows}}, nil
+}
+
+// queryPlanRows returns a row for each node of the plan.
+func queryPlanRows(plan *sppb.QueryPlan, analyze bool) ([]*spanner.Row, error) {
+	cols := []string{"ID", "KIND", "DISPLAY_NAME", "DESCRIPTION", "CHILDREN", "METADATA"}
+	if analyze {
+		cols = append(cols, "EXECUTION_STATS")
+	}
+	var planRows []*spanner.Row
+	for _, n := range plan.PlanNodes {
+		var children []string
+		for _, l := range n.ChildLinks {
+			children = append(children, fmt.Sprint(l.ChildIndex))
+		}
+		var description string
+		if n.ShortRepresentation != nil {
+			description = n.ShortRepresentation.Description
+		}
+		metadata, err := structJSON(n.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		vals := []interface{}{
+			int64(n.Index),
+			n.Kind.String(),
+			n.DisplayName,
+			description,
+			strings.Join(children, ","),
+			metadata,
+		}
+		if analyze {
+			stats, err := structJSON(n.ExecutionStats)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, stats)
+		}
+		row, err := spanner.NewRow(cols, vals)
+		if err != nil {
+			return nil, err
+		}
+		planRows = append(planRows, row)
+	}
+	return planRows, nil
+}
+
+// structJSON encodes s as a JSON object, or
+// returns an empty string if s is nil.
+func structJSON(s *structpb.Struct) (string, error) {
+	if s == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(s.AsMap())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
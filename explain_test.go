@@ -0,0 +1,100 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestQueryPlanRows(t *testing.T) {
+	metadata, err := structpb.NewStruct(map[string]interface{}{"scan_type": "TableScan", "scan_target": "tweets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := structpb.NewStruct(map[string]interface{}{"rows": map[string]interface{}{"total": "3"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := &sppb.QueryPlan{PlanNodes: []*sppb.PlanNode{
+		{
+			Index:       0,
+			Kind:        sppb.PlanNode_RELATIONAL,
+			DisplayName: "Distributed Union",
+			ChildLinks:  []*sppb.PlanNode_ChildLink{{ChildIndex: 1}, {ChildIndex: 2}},
+		},
+		{
+			Index:          1,
+			Kind:           sppb.PlanNode_RELATIONAL,
+			DisplayName:    "Scan",
+			Metadata:       metadata,
+			ExecutionStats: stats,
+		},
+		{
+			Index:               2,
+			Kind:                sppb.PlanNode_SCALAR,
+			DisplayName:         "Reference",
+			ShortRepresentation: &sppb.PlanNode_ShortRepresentation{Description: "id"},
+		},
+	}}
+
+	tests := []struct {
+		analyze  bool
+		wantCols []string
+		want     [][]driver.Value
+	}{
+		{
+			wantCols: []string{"ID", "KIND", "DISPLAY_NAME", "DESCRIPTION", "CHILDREN", "METADATA"},
+			want: [][]driver.Value{
+				{int64(0), "RELATIONAL", "Distributed Union", "", "1,2", ""},
+				{int64(1), "RELATIONAL", "Scan", "", "", `{"scan_target":"tweets","scan_type":"TableScan"}`},
+				{int64(2), "SCALAR", "Reference", "id", "", ""},
+			},
+		},
+		{
+			analyze:  true,
+			wantCols: []string{"ID", "KIND", "DISPLAY_NAME", "DESCRIPTION", "CHILDREN", "METADATA", "EXECUTION_STATS"},
+			want: [][]driver.Value{
+				{int64(0), "RELATIONAL", "Distributed Union", "", "1,2", "", ""},
+				{int64(1), "RELATIONAL", "Scan", "", "", `{"scan_target":"tweets","scan_type":"TableScan"}`, `{"rows":{"total":"3"}}`},
+				{int64(2), "SCALAR", "Reference", "id", "", "", ""},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		planRows, err := queryPlanRows(plan, tc.analyze)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &rows{it: &bufferedIterator{rows: planRows}}
+		if got := r.Columns(); !reflect.DeepEqual(got, tc.wantCols) {
+			t.Errorf("analyze=%v: want columns: %v, got: %v", tc.analyze, tc.wantCols, got)
+		}
+		for _, want := range tc.want {
+			got := make([]driver.Value, len(tc.wantCols))
+			if err := r.Next(got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("analyze=%v: want: %v, got: %v", tc.analyze, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+// EncodePartition and DecodePartition serialize
+// the partitions of partitioned queries.
+var (
+	EncodePartition = encodePartition
+	DecodePartition = decodePartition
+)
+
+// SessionStatsRegistered reports whether the session pool
+// statistics are exported to m.
+func SessionStatsRegistered(m Metrics) bool {
+	sessionStats.mu.Lock()
+	defer sessionStats.mu.Unlock()
+	for _, r := range sessionStats.drivers {
+		if r.metrics == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakespanner
+
+import (
+	"context"
+	"fmt"
+
+	lropb "google.golang.org/genproto/googleapis/longrunning"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// adminServer implements the schema updates of the database admin
+// API. It's a separate type from Server because the generated
+// Unimplemented servers can't be embedded in the same struct.
+type adminServer struct {
+	adminpb.UnimplementedDatabaseAdminServer
+	s *Server
+}
+
+// UpdateDatabaseDdl records the request and completes the schema
+// update immediately. The statements aren't validated; they can be
+// inspected with Requests.
+func (a *adminServer) UpdateDatabaseDdl(ctx context.Context, req *adminpb.UpdateDatabaseDdlRequest) (*lropb.Operation, error) {
+	a.s.record(req)
+	resp, err := anypb.New(&emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return &lropb.Operation{
+		Name:   fmt.Sprintf("%s/operations/%d", req.Database, a.s.newID()),
+		Done:   true,
+		Result: &lropb.Operation_Response{Response: resp},
+	}, nil
+}
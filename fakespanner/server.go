@@ -0,0 +1,498 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakespanner provides an in-process fake of the Spanner API
+// to test code that uses the driver without the emulator or a Spanner
+// instance.
+//
+// The fake doesn't execute SQL. Tests register the result of each
+// statement they execute, by SQL string:
+//
+//	s, db := fakespanner.NewTestDB(t, nil)
+//	s.MustPutStatementResult(t, "SELECT id, text FROM tweets", &fakespanner.StatementResult{
+//		Columns: []string{"id", "text"},
+//		Rows:    [][]interface{}{{int64(1), "hello"}},
+//	})
+//	rows, err := db.QueryContext(ctx, "SELECT id, text FROM tweets")
+//
+// The fake implements the RPCs the driver uses: the session RPCs,
+// ExecuteSql, ExecuteStreamingSql, ExecuteBatchDml, PartitionQuery,
+// BeginTransaction, including partitioned DML transactions, Commit
+// and Rollback, and UpdateDatabaseDdl of the database admin API.
+// The driver only uses SQL, Read, StreamingRead and PartitionRead
+// are unimplemented, like the other RPCs of the admin API.
+package fakespanner
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"google.golang.org/api/option"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Database is the name of the database served by the fake.
+const Database = "projects/fake/instances/fake/databases/fake"
+
+// ErrAborted is the error of a transaction aborted by Spanner.
+// Use it with PutCommitError to abort a commit.
+var ErrAborted = status.Error(codes.Aborted, "transaction was aborted")
+
+// StatementResult is the canned result of a statement.
+type StatementResult struct {
+	// Columns and Rows are the result of a query or of a DML
+	// statement with THEN RETURN. The values of the rows are
+	// Go values accepted by spanner.NewRow, e.g. int64, string
+	// or spanner.NullString.
+	Columns []string
+	Rows    [][]interface{}
+
+	// UpdateCount is the number of rows affected
+	// by a DML statement.
+	UpdateCount int64
+
+	// Err is returned instead of the result if set. It
+	// needs to be a gRPC status error, e.g. created with
+	// status.Error(codes.NotFound, "Table not found: tweets").
+	Err error
+}
+
+// Server is an in-process gRPC server that implements the subset
+// of the Spanner API used by the driver, and the schema updates
+// of the database admin API.
+type Server struct {
+	sppb.UnimplementedSpannerServer
+
+	srv *grpc.Server
+	lis net.Listener
+
+	mu           sync.Mutex
+	results      map[string]*result
+	commitErrors []error
+	requests     []proto.Message
+	sessions     map[string]*sppb.Session
+	nextID       int
+
+	// partitionedDML are the IDs of the partitioned
+	// DML transactions.
+	partitionedDML map[string]bool
+
+	// partitions are the SQL of the partitioned
+	// queries, by partition token.
+	partitions map[string]string
+}
+
+type result struct {
+	metadata    *sppb.ResultSetMetadata
+	rows        [][]*structpb.Value
+	updateCount int64
+	isDML       bool
+	err         error
+}
+
+// NewServer starts a server on a random local port.
+// The server needs to be closed once it's done.
+func NewServer() (*Server, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		srv:      grpc.NewServer(),
+		lis:      lis,
+		results:  make(map[string]*result),
+		sessions: make(map[string]*sppb.Session),
+
+		partitionedDML: make(map[string]bool),
+		partitions:     make(map[string]string),
+	}
+	sppb.RegisterSpannerServer(s.srv, s)
+	adminpb.RegisterDatabaseAdminServer(s.srv, &adminServer{s: s})
+	go s.srv.Serve(lis)
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() string {
+	return s.lis.Addr().String()
+}
+
+// ClientOptions returns the options that connect
+// a Spanner client to the server.
+func (s *Server) ClientOptions() []option.ClientOption {
+	return []option.ClientOption{
+		option.WithEndpoint(s.Addr()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+	}
+}
+
+// OpenDB returns a database that connects to the server with the
+// driver d, which can be nil. The database needs to be closed
+// before the server.
+func (s *Server) OpenDB(d *spannerdriver.Driver) *sql.DB {
+	var drv spannerdriver.Driver
+	if d != nil {
+		drv = *d
+	}
+	drv.Options = append(append([]option.ClientOption{}, drv.Options...), s.ClientOptions()...)
+	connector, _ := drv.OpenConnector(Database) // never fails
+	return sql.OpenDB(connector)
+}
+
+// NewTestDB starts a server and opens a database that connects to it
+// with the driver d, which can be nil. The database and the server
+// are closed when the test finishes.
+func NewTestDB(tb testing.TB, d *spannerdriver.Driver) (*Server, *sql.DB) {
+	tb.Helper()
+	s, err := NewServer()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	db := s.OpenDB(d)
+	tb.Cleanup(func() {
+		db.Close()
+		s.Close()
+	})
+	return s, db
+}
+
+// Close stops the server.
+func (s *Server) Close() {
+	s.srv.Stop()
+}
+
+// PutStatementResult registers the result of the statement with the
+// given SQL. It replaces the previous result of the statement, if any.
+func (s *Server) PutStatementResult(sql string, r *StatementResult) error {
+	res := &result{
+		metadata:    &sppb.ResultSetMetadata{RowType: &sppb.StructType{}},
+		updateCount: r.UpdateCount,
+		isDML:       r.UpdateCount > 0 || (len(r.Columns) == 0 && r.Err == nil),
+		err:         r.Err,
+	}
+	for i, values := range r.Rows {
+		row, err := spanner.NewRow(r.Columns, values)
+		if err != nil {
+			return fmt.Errorf("row %d: %v", i, err)
+		}
+		var protoRow []*structpb.Value
+		for j, name := range r.Columns {
+			var col spanner.GenericColumnValue
+			if err := row.Column(j, &col); err != nil {
+				return fmt.Errorf("row %d: %v", i, err)
+			}
+			if i == 0 {
+				res.metadata.RowType.Fields = append(res.metadata.RowType.Fields, &sppb.StructType_Field{
+					Name: name,
+					Type: col.Type,
+				})
+			}
+			protoRow = append(protoRow, col.Value)
+		}
+		res.rows = append(res.rows, protoRow)
+	}
+	if len(r.Rows) == 0 {
+		// Without rows, the types of the
+		// columns default to STRING.
+		for _, name := range r.Columns {
+			res.metadata.RowType.Fields = append(res.metadata.RowType.Fields, &sppb.StructType_Field{
+				Name: name,
+				Type: &sppb.Type{Code: sppb.TypeCode_STRING},
+			})
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[sql] = res
+	return nil
+}
+
+// MustPutStatementResult is like PutStatementResult
+// but fails the test if the result is invalid.
+func (s *Server) MustPutStatementResult(tb testing.TB, sql string, r *StatementResult) {
+	tb.Helper()
+	if err := s.PutStatementResult(sql, r); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// PutCommitError makes the next commit that has no error yet fail
+// with err, e.g. ErrAborted. Aborted transactions are retried by
+// the Spanner client unless the driver reports them.
+func (s *Server) PutCommitError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commitErrors = append(s.commitErrors, err)
+}
+
+// Requests returns the requests received by the server,
+// in order, except for the session management requests.
+func (s *Server) Requests() []proto.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]proto.Message(nil), s.requests...)
+}
+
+// ClearRequests forgets the requests received by the server.
+func (s *Server) ClearRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) record(req proto.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+func (s *Server) newID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) newSession(database string) *sppb.Session {
+	session := &sppb.Session{
+		Name:       fmt.Sprintf("%s/sessions/%d", database, s.newID()),
+		CreateTime: timestamppb.Now(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.Name] = session
+	return session
+}
+
+// newTransaction returns a new transaction if sel begins one.
+func (s *Server) newTransaction(sel *sppb.TransactionSelector) *sppb.Transaction {
+	begin := sel.GetBegin()
+	if begin == nil {
+		return nil
+	}
+	return s.beginTransaction(begin)
+}
+
+func (s *Server) beginTransaction(opts *sppb.TransactionOptions) *sppb.Transaction {
+	tx := &sppb.Transaction{Id: []byte(fmt.Sprintf("tx%d", s.newID()))}
+	if opts.GetReadOnly() != nil {
+		tx.ReadTimestamp = timestamppb.Now()
+	}
+	if opts.GetPartitionedDml() != nil {
+		s.mu.Lock()
+		s.partitionedDML[string(tx.Id)] = true
+		s.mu.Unlock()
+	}
+	return tx
+}
+
+// execute returns the result set of the statement of req.
+func (s *Server) execute(req *sppb.ExecuteSqlRequest) (*sppb.ResultSet, error) {
+	if err := s.checkSession(req.Session); err != nil {
+		return nil, err
+	}
+	if req.PartitionToken != nil {
+		s.mu.Lock()
+		sql, ok := s.partitions[string(req.PartitionToken)]
+		s.mu.Unlock()
+		if !ok || sql != req.Sql {
+			return nil, status.Errorf(codes.InvalidArgument, "fakespanner: invalid partition token for statement %q", req.Sql)
+		}
+	}
+	r, err := s.lookup(req.Sql)
+	if err != nil {
+		return nil, err
+	}
+	rs := r.resultSet(s.newTransaction(req.Transaction))
+	s.mu.Lock()
+	pdml := s.partitionedDML[string(req.Transaction.GetId())]
+	s.mu.Unlock()
+	if pdml && rs.Stats != nil {
+		// Partitioned DML only knows a lower bound.
+		rs.Stats.RowCount = &sppb.ResultSetStats_RowCountLowerBound{RowCountLowerBound: r.updateCount}
+	}
+	return rs, nil
+}
+
+func (s *Server) lookup(sql string) (*result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.results[sql]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "fakespanner: no result for statement %q", sql)
+	}
+	return r, r.err
+}
+
+func (s *Server) checkSession(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[name]; !ok {
+		return status.Errorf(codes.NotFound, "Session not found: %s", name)
+	}
+	return nil
+}
+
+func (s *Server) CreateSession(ctx context.Context, req *sppb.CreateSessionRequest) (*sppb.Session, error) {
+	return s.newSession(req.Database), nil
+}
+
+func (s *Server) BatchCreateSessions(ctx context.Context, req *sppb.BatchCreateSessionsRequest) (*sppb.BatchCreateSessionsResponse, error) {
+	resp := &sppb.BatchCreateSessionsResponse{}
+	for i := int32(0); i < req.SessionCount; i++ {
+		resp.Session = append(resp.Session, s.newSession(req.Database))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetSession(ctx context.Context, req *sppb.GetSessionRequest) (*sppb.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "Session not found: %s", req.Name)
+	}
+	return session, nil
+}
+
+func (s *Server) DeleteSession(ctx context.Context, req *sppb.DeleteSessionRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ExecuteSql(ctx context.Context, req *sppb.ExecuteSqlRequest) (*sppb.ResultSet, error) {
+	s.record(req)
+	return s.execute(req)
+}
+
+func (s *Server) ExecuteStreamingSql(req *sppb.ExecuteSqlRequest, stream sppb.Spanner_ExecuteStreamingSqlServer) error {
+	s.record(req)
+	rs, err := s.execute(req)
+	if err != nil {
+		return err
+	}
+	prs := &sppb.PartialResultSet{
+		Metadata: rs.Metadata,
+		Stats:    rs.Stats,
+	}
+	for _, row := range rs.Rows {
+		prs.Values = append(prs.Values, row.Values...)
+	}
+	return stream.Send(prs)
+}
+
+func (s *Server) ExecuteBatchDml(ctx context.Context, req *sppb.ExecuteBatchDmlRequest) (*sppb.ExecuteBatchDmlResponse, error) {
+	s.record(req)
+	if err := s.checkSession(req.Session); err != nil {
+		return nil, err
+	}
+	tx := s.newTransaction(req.Transaction)
+	resp := &sppb.ExecuteBatchDmlResponse{Status: status.New(codes.OK, "").Proto()}
+	for _, stmt := range req.Statements {
+		r, err := s.lookup(stmt.Sql)
+		if err != nil {
+			resp.Status = status.Convert(err).Proto()
+			break
+		}
+		resp.ResultSets = append(resp.ResultSets, r.resultSet(tx))
+		tx = nil // returned with the first result set only
+	}
+	return resp, nil
+}
+
+// PartitionQuery returns a single partition, which
+// returns the whole result of the query when executed.
+func (s *Server) PartitionQuery(ctx context.Context, req *sppb.PartitionQueryRequest) (*sppb.PartitionResponse, error) {
+	s.record(req)
+	if err := s.checkSession(req.Session); err != nil {
+		return nil, err
+	}
+	if _, err := s.lookup(req.Sql); err != nil {
+		return nil, err
+	}
+	token := fmt.Sprintf("partition%d", s.newID())
+	s.mu.Lock()
+	s.partitions[token] = req.Sql
+	s.mu.Unlock()
+	return &sppb.PartitionResponse{
+		Partitions:  []*sppb.Partition{{PartitionToken: []byte(token)}},
+		Transaction: s.newTransaction(req.Transaction),
+	}, nil
+}
+
+func (s *Server) BeginTransaction(ctx context.Context, req *sppb.BeginTransactionRequest) (*sppb.Transaction, error) {
+	s.record(req)
+	if err := s.checkSession(req.Session); err != nil {
+		return nil, err
+	}
+	return s.beginTransaction(req.Options), nil
+}
+
+func (s *Server) Commit(ctx context.Context, req *sppb.CommitRequest) (*sppb.CommitResponse, error) {
+	s.record(req)
+	if err := s.checkSession(req.Session); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.commitErrors) > 0 {
+		err := s.commitErrors[0]
+		s.commitErrors = s.commitErrors[1:]
+		return nil, err
+	}
+	return &sppb.CommitResponse{CommitTimestamp: timestamppb.New(time.Now())}, nil
+}
+
+func (s *Server) Rollback(ctx context.Context, req *sppb.RollbackRequest) (*emptypb.Empty, error) {
+	s.record(req)
+	if err := s.checkSession(req.Session); err != nil {
+		return nil, err
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// resultSet returns the result with the transaction tx, if set.
+func (r *result) resultSet(tx *sppb.Transaction) *sppb.ResultSet {
+	metadata := proto.Clone(r.metadata).(*sppb.ResultSetMetadata)
+	metadata.Transaction = tx
+	rs := &sppb.ResultSet{Metadata: metadata}
+	for _, row := range r.rows {
+		rs.Rows = append(rs.Rows, &structpb.ListValue{Values: row})
+	}
+	if r.isDML {
+		rs.Stats = &sppb.ResultSetStats{
+			RowCount: &sppb.ResultSetStats_RowCountExact{RowCountExact: r.updateCount},
+		}
+	}
+	return rs
+}
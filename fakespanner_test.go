@@ -0,0 +1,281 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFakeQuery(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, "SELECT id, text FROM tweets", &fakespanner.StatementResult{
+		Columns: []string{"id", "text"},
+		Rows: [][]interface{}{
+			{int64(1), "hello"},
+			{int64(2), "world"},
+		},
+	})
+
+	rows, err := db.QueryContext(spannerdriver.WithRequestTag(ctx, "app=timeline"), "SELECT id, text FROM tweets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id int64
+		var text string
+		if err := rows.Scan(&id, &text); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, text)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hello", "world"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rows: want: %v, got: %v", want, got)
+	}
+
+	var tag string
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			tag = req.RequestOptions.GetRequestTag()
+		}
+	}
+	if want := "app=timeline"; tag != want {
+		t.Errorf("request tag: want: %q, got: %q", want, tag)
+	}
+}
+
+func TestFakeErrors(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, "SELECT * FROM missing", &fakespanner.StatementResult{
+		Err: status.Error(codes.NotFound, "Table not found: missing"),
+	})
+
+	rows, err := db.QueryContext(ctx, "SELECT * FROM missing")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if got := spanner.ErrCode(err); got != codes.NotFound {
+		t.Errorf("error code: want: %v, got: %v (%v)", codes.NotFound, got, err)
+	}
+}
+
+func TestFakeDML(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, "DELETE FROM tweets WHERE TRUE", &fakespanner.StatementResult{UpdateCount: 3})
+
+	res, err := db.ExecContext(ctx, "DELETE FROM tweets WHERE TRUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 3 {
+		t.Errorf("rows affected: want: 3, got: %v, %v", n, err)
+	}
+
+	// Aborted transactions of DML statements are retried.
+	s.PutCommitError(fakespanner.ErrAborted)
+	if _, err := db.ExecContext(ctx, "DELETE FROM tweets WHERE TRUE"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeAbortedTransaction(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, "UPDATE tweets SET likes = likes + 1 WHERE TRUE", &fakespanner.StatementResult{UpdateCount: 1})
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE tweets SET likes = likes + 1 WHERE TRUE"); err != nil {
+		t.Fatal(err)
+	}
+	s.PutCommitError(fakespanner.ErrAborted)
+	if err := tx.Commit(); !errors.Is(err, spannerdriver.ErrAbortedTransaction) {
+		t.Errorf("Commit: want: %v, got: %v", spannerdriver.ErrAbortedTransaction, err)
+	}
+}
+
+func TestFakePartitionedQuery(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, "SELECT text FROM tweets", &fakespanner.StatementResult{
+		Columns: []string{"text"},
+		Rows:    [][]interface{}{{"hello"}, {"world"}},
+	})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	texts := func(rows *sql.Rows, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		var texts []string
+		for rows.Next() {
+			var text string
+			if err := rows.Scan(&text); err != nil {
+				t.Fatal(err)
+			}
+			texts = append(texts, text)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return texts
+	}
+	want := []string{"hello", "world"}
+	if got := texts(conn.QueryContext(ctx, "RUN PARTITIONED QUERY SELECT text FROM tweets")); !reflect.DeepEqual(got, want) {
+		t.Errorf("RUN PARTITIONED QUERY: want: %v, got: %v", want, got)
+	}
+
+	var pq *spannerdriver.PartitionedQuery
+	err = conn.Raw(func(driverConn interface{}) error {
+		var err error
+		pq, err = driverConn.(spannerdriver.Conn).PartitionQuery(ctx, "SELECT text FROM tweets")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close(ctx)
+	if len(pq.Partitions) != 1 {
+		t.Fatalf("partitions: want: 1, got: %d", len(pq.Partitions))
+	}
+	if got := texts(spannerdriver.ExecutePartition(ctx, db, pq.Partitions[0])); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExecutePartition: want: %v, got: %v", want, got)
+	}
+	var partitioned int
+	for _, req := range s.Requests() {
+		if _, ok := req.(*sppb.PartitionQueryRequest); ok {
+			partitioned++
+		}
+	}
+	if partitioned != 2 {
+		t.Errorf("partition requests: want: 2, got: %d", partitioned)
+	}
+}
+
+func TestFakePartitionedDML(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, "DELETE FROM tweets WHERE TRUE", &fakespanner.StatementResult{UpdateCount: 10})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET AUTOCOMMIT_DML_MODE = 'PARTITIONED_NON_ATOMIC'"); err != nil {
+		t.Fatal(err)
+	}
+	res, err := conn.ExecContext(ctx, "DELETE FROM tweets WHERE TRUE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 10 {
+		t.Errorf("rows affected: want: 10, got: %d", n)
+	}
+	var pdml bool
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.BeginTransactionRequest); ok && req.Options.GetPartitionedDml() != nil {
+			pdml = true
+		}
+	}
+	if !pdml {
+		t.Error("the statement didn't run in a partitioned DML transaction")
+	}
+}
+
+// paramsLogger records the params of the logged statements.
+type paramsLogger struct {
+	mu     sync.Mutex
+	params []interface{}
+}
+
+func (l *paramsLogger) log(args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "params" {
+			l.params = append(l.params, args[i+1])
+		}
+	}
+}
+
+func (l *paramsLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(args)
+}
+
+func (l *paramsLogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(args)
+}
+
+func (l *paramsLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log(args)
+}
+
+func TestFakeLoggedParams(t *testing.T) {
+	ctx := context.Background()
+	l := &paramsLogger{}
+	s, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{Logger: l})
+	s.MustPutStatementResult(t, "UPDATE tweets SET likes = @likes WHERE id = @id", &fakespanner.StatementResult{UpdateCount: 1})
+	s.MustPutStatementResult(t, "SELECT text FROM tweets WHERE id = @p1", &fakespanner.StatementResult{
+		Columns: []string{"text"},
+		Rows:    [][]interface{}{{"hello"}},
+	})
+
+	// The positional argument is bound to @id.
+	if _, err := db.ExecContext(ctx, "UPDATE tweets SET likes = @likes WHERE id = @id", sql.Named("likes", int64(10)), int64(1)); err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	if err := db.QueryRowContext(ctx, "SELECT text FROM tweets WHERE id = ?", int64(1)).Scan(&text); err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"likes": int64(10), "id": int64(1)},
+		map[string]interface{}{"p1": int64(1)},
+	}
+	if !reflect.DeepEqual(l.params, want) {
+		t.Errorf("params: want: %v, got: %v", want, l.params)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Faults injects errors and delays into the driver, to test how
+// applications handle them. It is meant for tests only.
+//
+// Operations are counted from 1 across the connections of a
+// connector. Client statements, such as SET, are not counted.
+// Zero disables a fault. The faults apply to replayed statements
+// too, except for AbortCommit as transactions aren't replayed,
+// and they are never recorded.
+type Faults struct {
+	// AbortCommit makes Spanner abort the Nth commit of a
+	// read-write transaction, including the transactions of DML
+	// statements executed outside of explicit transactions.
+	// Transactions of DML statements are retried if
+	// RETRY_ABORTS_INTERNALLY is enabled, explicit transactions
+	// fail with ErrAbortedTransaction.
+	AbortCommit int
+
+	// UnavailableExec fails the Nth statement executed
+	// with ExecContext with an UNAVAILABLE error.
+	UnavailableExec int
+
+	// UnavailableQuery fails the Nth query with an
+	// UNAVAILABLE error.
+	UnavailableQuery int
+
+	// RowDelay delays each row read from the results of
+	// queries. The delay counts towards the statement timeout.
+	RowDelay time.Duration
+}
+
+// faultInjector injects the faults of a connector.
+type faultInjector struct {
+	Faults
+
+	commits int64
+	execs   int64
+	queries int64
+}
+
+func newFaultInjector(f *Faults) *faultInjector {
+	if f == nil {
+		return nil
+	}
+	return &faultInjector{Faults: *f}
+}
+
+func injectedError(code codes.Code) error {
+	return spanner.ToSpannerError(status.Errorf(code, "injected %v error", code))
+}
+
+// commit returns an ABORTED error if the
+// commit that is about to start is aborted.
+func (f *faultInjector) commit() error {
+	if f == nil || f.AbortCommit <= 0 {
+		return nil
+	}
+	if atomic.AddInt64(&f.commits, 1) == int64(f.AbortCommit) {
+		return injectedError(codes.Aborted)
+	}
+	return nil
+}
+
+// exec returns an UNAVAILABLE error if query fails.
+func (f *faultInjector) exec(query string) error {
+	if f == nil || f.UnavailableExec <= 0 || isClientStmt(query) {
+		return nil
+	}
+	if atomic.AddInt64(&f.execs, 1) == int64(f.UnavailableExec) {
+		return injectedError(codes.Unavailable)
+	}
+	return nil
+}
+
+// query returns an UNAVAILABLE error if query fails.
+func (f *faultInjector) query(query string) error {
+	if f == nil || f.UnavailableQuery <= 0 || isClientStmt(query) {
+		return nil
+	}
+	if atomic.AddInt64(&f.queries, 1) == int64(f.UnavailableQuery) {
+		return injectedError(codes.Unavailable)
+	}
+	return nil
+}
+
+// delayRow waits for the row delay. It returns an error if
+// the statement deadline d, which can be nil, expires first.
+func (f *faultInjector) delayRow(d *statementDeadline) error {
+	if f == nil || f.RowDelay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(f.RowDelay)
+	defer t.Stop()
+	if d == nil {
+		<-t.C
+		return nil
+	}
+	select {
+	case <-t.C:
+		return nil
+	case <-d.ctx.Done():
+		return d.err(d.ctx.Err())
+	}
+}
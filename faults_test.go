@@ -0,0 +1,172 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	"google.golang.org/grpc/codes"
+)
+
+const updateTweets = "UPDATE tweets SET likes = likes + 1 WHERE TRUE"
+
+func newFaultyDB(t *testing.T, d *spannerdriver.Driver) *sql.DB {
+	s, db := fakespanner.NewTestDB(t, d)
+	s.MustPutStatementResult(t, updateTweets, &fakespanner.StatementResult{UpdateCount: 1})
+	s.MustPutStatementResult(t, "SELECT text FROM tweets", &fakespanner.StatementResult{
+		Columns: []string{"text"},
+		Rows:    [][]interface{}{{"hello"}, {"world"}},
+	})
+	return db
+}
+
+func readAll(rows *sql.Rows) error {
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func TestFaultsAbortCommit(t *testing.T) {
+	ctx := context.Background()
+	db := newFaultyDB(t, &spannerdriver.Driver{Faults: &spannerdriver.Faults{AbortCommit: 2}})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The first commit succeeds, the second one is aborted.
+	for i, want := range []error{nil, spannerdriver.ErrAbortedTransaction, nil} {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, updateTweets); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); !errors.Is(err, want) {
+			t.Errorf("commit %d: want: %v, got: %v", i+1, want, err)
+		}
+	}
+
+	// DML statements are retried if RETRY_ABORTS_INTERNALLY is enabled.
+	db = newFaultyDB(t, &spannerdriver.Driver{Faults: &spannerdriver.Faults{AbortCommit: 1}})
+	if _, err := db.ExecContext(ctx, updateTweets); err != nil {
+		t.Errorf("retried DML: %v", err)
+	}
+	db = newFaultyDB(t, &spannerdriver.Driver{Faults: &spannerdriver.Faults{AbortCommit: 1}})
+	conn, err = db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = false"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, updateTweets); !errors.Is(err, spannerdriver.ErrAbortedTransaction) {
+		t.Errorf("DML: want: %v, got: %v", spannerdriver.ErrAbortedTransaction, err)
+	}
+}
+
+func TestFaultsUnavailable(t *testing.T) {
+	ctx := context.Background()
+	db := newFaultyDB(t, &spannerdriver.Driver{Faults: &spannerdriver.Faults{UnavailableExec: 2, UnavailableQuery: 2}})
+
+	for i, want := range []codes.Code{codes.OK, codes.Unavailable, codes.OK} {
+		// Client statements aren't counted.
+		if _, err := db.ExecContext(ctx, "SET STATEMENT_TAG = 'app=timeline'"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := db.ExecContext(ctx, updateTweets)
+		if got := spanner.ErrCode(err); got != want {
+			t.Errorf("exec %d: want: %v, got: %v", i+1, want, err)
+		}
+		rows, err := db.QueryContext(ctx, "SELECT text FROM tweets")
+		if err == nil {
+			err = readAll(rows)
+		}
+		if got := spanner.ErrCode(err); got != want {
+			t.Errorf("query %d: want: %v, got: %v", i+1, want, err)
+		}
+	}
+}
+
+func TestFaultsRowDelay(t *testing.T) {
+	ctx := context.Background()
+	db := newFaultyDB(t, &spannerdriver.Driver{Faults: &spannerdriver.Faults{RowDelay: 50 * time.Millisecond}})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	rows, err := conn.QueryContext(ctx, "SELECT text FROM tweets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := readAll(rows); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond {
+		t.Errorf("reading the delayed rows took %v, want at least 100ms", d)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SET STATEMENT_TIMEOUT = '10ms'"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = conn.QueryContext(ctx, "SELECT text FROM tweets")
+	if err == nil {
+		err = readAll(rows)
+	}
+	if !errors.Is(err, spannerdriver.ErrStatementTimeout) {
+		t.Errorf("delayed rows: want: %v, got: %v", spannerdriver.ErrStatementTimeout, err)
+	}
+}
+
+func TestFaultsNotRecorded(t *testing.T) {
+	ctx := context.Background()
+	file := tempFile(t, "tweets.json")
+	rec := &spannerdriver.Recording{Mode: spannerdriver.Record, File: file}
+	db := newFaultyDB(t, &spannerdriver.Driver{Recording: rec, Faults: &spannerdriver.Faults{UnavailableExec: 1}})
+	if _, err := db.ExecContext(ctx, updateTweets); spanner.ErrCode(err) != codes.Unavailable {
+		t.Fatalf("first DML: want: %v, got: %v", codes.Unavailable, err)
+	}
+	if _, err := db.ExecContext(ctx, updateTweets); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Only the successful statement is replayed.
+	connector, err := (&spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Replay, File: file},
+	}).OpenConnector(fakespanner.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, updateTweets); err != nil {
+		t.Errorf("replayed DML: %v", err)
+	}
+}
@@ -1,11 +1,16 @@
 module github.com/rakyll/go-sql-driver-spanner
 
-go 1.14
+go 1.15
 
 require (
-	cloud.google.com/go/spanner v1.2.1
+	cloud.google.com/go/spanner v1.25.0
 	github.com/jinzhu/gorm v1.9.12
-	golang.org/x/tools v0.0.0-20200221224223-e1da425f72fd // indirect
-	google.golang.org/api v0.17.0
-	google.golang.org/genproto v0.0.0-20200212174721-66ed5ce911ce
+	go.opencensus.io v0.23.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
+	google.golang.org/api v0.54.0
+	google.golang.org/genproto v0.0.0-20210821163610-241b8fcbd6c8
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.27.1
 )
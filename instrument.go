@@ -0,0 +1,204 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/rakyll/go-sql-driver-spanner"
+
+// Attributes of the spans of the driver, in addition to
+// the db.system, db.name and db.statement conventions.
+const (
+	paramCountKey      = attribute.Key("spanner.param_count")
+	transactionTypeKey = attribute.Key("spanner.transaction_type")
+	rowsReturnedKey    = attribute.Key("spanner.rows_returned")
+	rowsAffectedKey    = attribute.Key("spanner.rows_affected")
+	attemptsKey        = attribute.Key("spanner.transaction_attempts")
+	mutationCountKey   = attribute.Key("spanner.mutation_count")
+	grpcStatusKey      = attribute.Key("rpc.grpc.status_code")
+)
+
+// instrumentation records the spans and the metrics of the
+// operations of a connection. A nil *instrumentation records
+// no-op spans and no metrics.
+type instrumentation struct {
+	tracer   trace.Tracer
+	metrics  Metrics
+	logger   Logger
+	database string
+
+	// slowThreshold is the duration after which
+	// statements are logged as slow, if positive.
+	slowThreshold time.Duration
+
+	// redact is set if literals are removed from the
+	// recorded statements and parameter values.
+	redact bool
+}
+
+func newInstrumentation(d *Driver, database string) *instrumentation {
+	tp := d.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return &instrumentation{
+		tracer:   tp.Tracer(instrumentationName, trace.WithInstrumentationVersion(userAgent)),
+		metrics:  d.Metrics,
+		logger:   d.Logger,
+		database: database,
+		redact:   d.RedactStatements,
+
+		slowThreshold: d.SlowStatementThreshold,
+	}
+}
+
+// operation is an operation of the driver in progress.
+type operation struct {
+	inst  *instrumentation
+	name  string
+	ctx   context.Context
+	span  trace.Span
+	start time.Time
+
+	// isStatement is set if the operation executes the
+	// statement query with the arguments args.
+	isStatement bool
+	query       string
+	args        []driver.NamedValue
+
+	// isMutations is set if the operation applies
+	// the number of mutations mutations.
+	isMutations bool
+	mutations   int
+
+	// rows is the number of rows affected or returned
+	// by the statement, named by rowsKey if known.
+	rowsKey string
+	rows    int64
+}
+
+// start starts an operation, e.g. ExecContext. The returned
+// context carries the span of the operation.
+func (in *instrumentation) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	op := &operation{inst: in, name: name, start: time.Now()}
+	if in == nil {
+		op.ctx, op.span = trace.NewNoopTracerProvider().Tracer("").Start(ctx, name)
+		return op.ctx, op
+	}
+	attrs = append(attrs,
+		attribute.String("db.system", "spanner"),
+		attribute.String("db.name", in.database))
+	op.ctx, op.span = in.tracer.Start(ctx, "spanner."+name,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return op.ctx, op
+}
+
+// startStatement starts an operation that executes the
+// statement query with the arguments args.
+func (in *instrumentation) startStatement(ctx context.Context, name, query string, args []driver.NamedValue, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	attrs = append(attrs, in.statement(query), paramCountKey.Int(len(args)))
+	ctx, op := in.start(ctx, name, attrs...)
+	op.isStatement = true
+	op.query = query
+	op.args = args
+	return ctx, op
+}
+
+// startMutations starts an operation that applies n mutations.
+func (in *instrumentation) startMutations(ctx context.Context, n int, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	attrs = append(attrs, mutationCountKey.Int(n))
+	ctx, op := in.start(ctx, "ApplyMutations", attrs...)
+	op.isMutations = true
+	op.mutations = n
+	return ctx, op
+}
+
+// setRowsAffected records the number of rows
+// affected by the statement of the operation.
+func (op *operation) setRowsAffected(n int64) {
+	op.span.SetAttributes(rowsAffectedKey.Int64(n))
+	op.inst.count(op.ctx, MetricRowsAffected, n)
+	op.rowsKey, op.rows = "rows_affected", n
+}
+
+// setRowsReturned records the number of rows
+// returned by the query of the operation.
+func (op *operation) setRowsReturned(n int64) {
+	op.span.SetAttributes(rowsReturnedKey.Int64(n))
+	op.inst.count(op.ctx, MetricRowsReturned, n)
+	op.rowsKey, op.rows = "rows_returned", n
+}
+
+// end records the result of the operation.
+func (op *operation) end(err error) {
+	if err == io.EOF {
+		err = nil
+	}
+	op.span.SetAttributes(grpcStatusKey.Int(int(spanner.ErrCode(err))))
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+	}
+	op.span.End()
+	d := time.Since(op.start)
+	if op.inst != nil && op.inst.metrics != nil {
+		op.inst.metrics.RecordLatency(op.ctx, op.name, d, err)
+	}
+	op.logStatement(d, err)
+}
+
+// count adds delta to the counter of the metrics named name.
+func (in *instrumentation) count(ctx context.Context, name string, delta int64) {
+	if in != nil && in.metrics != nil {
+		in.metrics.AddCount(ctx, name, delta)
+	}
+}
+
+// statement returns the db.statement attribute of q.
+func (in *instrumentation) statement(q string) attribute.KeyValue {
+	return attribute.String("db.statement", in.statementText(q))
+}
+
+// statementText returns q as it is recorded.
+func (in *instrumentation) statementText(q string) string {
+	if in != nil && in.redact {
+		return internal.RedactLiterals(q)
+	}
+	return q
+}
+
+// transactionType returns the type of the current
+// transaction of the connection for spans.
+func (c *conn) transactionType() attribute.KeyValue {
+	switch {
+	case c.roTx != nil:
+		return transactionTypeKey.String("read_only")
+	case c.rwTx != nil:
+		return transactionTypeKey.String("read_write")
+	}
+	return transactionTypeKey.String("none")
+}
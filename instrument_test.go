@@ -0,0 +1,148 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpans(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c := &conn{
+		inst:     newInstrumentation(&Driver{TracerProvider: tp, RedactStatements: true}, "projects/p/instances/i/databases/d"),
+		defaults: defaultConnOptions(),
+		vars:     defaultConnOptions(),
+	}
+
+	if _, err := c.ExecContext(ctx, "SET STATEMENT_TAG = 'app=timeline'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "SET FOO = 1", nil); err == nil {
+		t.Fatal("SET FOO: want error")
+	}
+	rows, err := c.QueryContext(ctx, "SHOW VARIABLE STATEMENT_TAG", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := make([]driver.Value, 1)
+	for err == nil {
+		err = rows.Next(dest)
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("want 3 spans, got: %d", len(spans))
+	}
+	attrs := func(i int) map[attribute.Key]attribute.Value {
+		m := make(map[attribute.Key]attribute.Value)
+		for _, kv := range spans[i].Attributes() {
+			m[kv.Key] = kv.Value
+		}
+		return m
+	}
+
+	if got, want := spans[0].Name(), "spanner.ExecContext"; got != want {
+		t.Errorf("span name: want: %q, got: %q", want, got)
+	}
+	if got, want := attrs(0)["db.statement"].AsString(), "SET STATEMENT_TAG = ?"; got != want {
+		t.Errorf("redacted statement: want: %q, got: %q", want, got)
+	}
+	if got, want := attrs(0)["db.name"].AsString(), "projects/p/instances/i/databases/d"; got != want {
+		t.Errorf("database: want: %q, got: %q", want, got)
+	}
+	if got, want := attrs(0)[transactionTypeKey].AsString(), "none"; got != want {
+		t.Errorf("transaction type: want: %q, got: %q", want, got)
+	}
+	if got := spans[1].Status().Code; got != codes.Error {
+		t.Errorf("failed statement: want error status, got: %v", got)
+	}
+	if got, want := spans[2].Name(), "spanner.QueryContext"; got != want {
+		t.Errorf("span name: want: %q, got: %q", want, got)
+	}
+	if got, want := attrs(2)[rowsReturnedKey].AsInt64(), int64(1); got != want {
+		t.Errorf("rows returned: want: %d, got: %d", want, got)
+	}
+}
+
+type fakeMetrics struct {
+	latencies []string
+	counts    map[string]int64
+}
+
+func (m *fakeMetrics) RecordLatency(ctx context.Context, op string, d time.Duration, err error) {
+	if err != nil {
+		op += " (error)"
+	}
+	m.latencies = append(m.latencies, op)
+}
+
+func (m *fakeMetrics) AddCount(ctx context.Context, name string, delta int64) {
+	m.counts[name] += delta
+}
+
+func (m *fakeMetrics) RecordGauge(name string, value int64, labels map[string]string) {}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	m := &fakeMetrics{counts: make(map[string]int64)}
+	c := &conn{
+		inst:     newInstrumentation(&Driver{Metrics: m}, "projects/p/instances/i/databases/d"),
+		defaults: defaultConnOptions(),
+		vars:     defaultConnOptions(),
+	}
+
+	if _, err := c.ExecContext(ctx, "SET STATEMENT_TAG = 'app=timeline'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExecContext(ctx, "SET FOO = 1", nil); err == nil {
+		t.Fatal("SET FOO: want error")
+	}
+	rows, err := c.QueryContext(ctx, "SHOW VARIABLE STATEMENT_TAG", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := make([]driver.Value, 1)
+	for err == nil {
+		err = rows.Next(dest)
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	want := []string{"ExecContext", "ExecContext (error)", "QueryContext"}
+	if !reflect.DeepEqual(m.latencies, want) {
+		t.Errorf("latencies: want: %v, got: %v", want, m.latencies)
+	}
+	if got, want := m.counts[MetricRowsReturned], int64(1); got != want {
+		t.Errorf("rows returned: want: %d, got: %d", want, got)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Interceptor has hooks that are called around the operations of
+// the driver, e.g. to audit statements, to enforce conditions on
+// them or to rewrite them. Every hook is optional.
+//
+// The Before hooks can stop the operation: if one returns an error,
+// the operation fails with the error. The After hooks are called
+// with the result of the operation, unless a Before hook stopped it.
+//
+// The statement hooks are called for the queries and the DML
+// statements sent to Spanner, including partitioned queries. They
+// are not called for DDL and client statements, such as SET or
+// RUN BATCH.
+type Interceptor struct {
+	// BeforeExec is called before a DML statement is executed
+	// and can modify it.
+	BeforeExec func(ctx context.Context, stmt *spanner.Statement) error
+
+	// AfterExec is called after a DML statement is executed. In a
+	// DML batch, it is called when the statement is buffered and
+	// rowsAffected is zero.
+	AfterExec func(ctx context.Context, stmt spanner.Statement, rowsAffected int64, err error)
+
+	// BeforeQuery is called before a query, including a DML
+	// statement with THEN RETURN, is executed and can modify it.
+	BeforeQuery func(ctx context.Context, stmt *spanner.Statement) error
+
+	// AfterQuery is called when the rows of a query are done or
+	// closed, or when the query fails to start. For PartitionQuery,
+	// it is called once the query is partitioned. For RUN PARTITION,
+	// the hooks are called with the partitioned query, which the
+	// BeforeQuery hooks can't modify.
+	AfterQuery func(ctx context.Context, stmt spanner.Statement, rowsReturned int64, err error)
+
+	// BeforeBegin is called before a transaction begins.
+	BeforeBegin func(ctx context.Context, opts driver.TxOptions) error
+
+	// AfterBegin is called after a transaction begins.
+	AfterBegin func(ctx context.Context, opts driver.TxOptions, err error)
+
+	// BeforeCommit is called before a transaction commits. If it
+	// returns an error, the transaction is rolled back instead.
+	BeforeCommit func(ctx context.Context) error
+
+	// AfterCommit is called after a transaction commits.
+	AfterCommit func(ctx context.Context, err error)
+
+	// BeforeRollback is called before a transaction is rolled
+	// back. The transaction is rolled back even if it returns an
+	// error, which is then returned by Rollback.
+	BeforeRollback func(ctx context.Context) error
+
+	// AfterRollback is called after a transaction is rolled back.
+	AfterRollback func(ctx context.Context, err error)
+}
+
+// interceptors calls the hooks of a list of interceptors. Before
+// hooks are called in order and After hooks in reverse order.
+type interceptors []Interceptor
+
+func (is interceptors) beforeExec(ctx context.Context, stmt *spanner.Statement) error {
+	for _, i := range is {
+		if i.BeforeExec != nil {
+			if err := i.BeforeExec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (is interceptors) afterExec(ctx context.Context, stmt spanner.Statement, rowsAffected int64, err error) {
+	for n := len(is) - 1; n >= 0; n-- {
+		if is[n].AfterExec != nil {
+			is[n].AfterExec(ctx, stmt, rowsAffected, err)
+		}
+	}
+}
+
+func (is interceptors) beforeQuery(ctx context.Context, stmt *spanner.Statement) error {
+	for _, i := range is {
+		if i.BeforeQuery != nil {
+			if err := i.BeforeQuery(ctx, stmt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (is interceptors) afterQuery(ctx context.Context, stmt spanner.Statement, rowsReturned int64, err error) {
+	for n := len(is) - 1; n >= 0; n-- {
+		if is[n].AfterQuery != nil {
+			is[n].AfterQuery(ctx, stmt, rowsReturned, err)
+		}
+	}
+}
+
+func (is interceptors) beforeBegin(ctx context.Context, opts driver.TxOptions) error {
+	for _, i := range is {
+		if i.BeforeBegin != nil {
+			if err := i.BeforeBegin(ctx, opts); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (is interceptors) afterBegin(ctx context.Context, opts driver.TxOptions, err error) {
+	for n := len(is) - 1; n >= 0; n-- {
+		if is[n].AfterBegin != nil {
+			is[n].AfterBegin(ctx, opts, err)
+		}
+	}
+}
+
+func (is interceptors) beforeCommit(ctx context.Context) error {
+	for _, i := range is {
+		if i.BeforeCommit != nil {
+			if err := i.BeforeCommit(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (is interceptors) afterCommit(ctx context.Context, err error) {
+	for n := len(is) - 1; n >= 0; n-- {
+		if is[n].AfterCommit != nil {
+			is[n].AfterCommit(ctx, err)
+		}
+	}
+}
+
+// beforeRollback calls all the BeforeRollback hooks, since
+// the transaction is rolled back anyway, and returns the
+// first error.
+func (is interceptors) beforeRollback(ctx context.Context) error {
+	var first error
+	for _, i := range is {
+		if i.BeforeRollback != nil {
+			if err := i.BeforeRollback(ctx); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func (is interceptors) afterRollback(ctx context.Context, err error) {
+	for n := len(is) - 1; n >= 0; n-- {
+		if is[n].AfterRollback != nil {
+			is[n].AfterRollback(ctx, err)
+		}
+	}
+}
+
+// prepareQuery prepares a query to be sent to Spanner
+// and lets the interceptors modify it.
+func (c *conn) prepareQuery(ctx context.Context, query string, args []driver.NamedValue) (spanner.Statement, error) {
+	ss, err := prepareSpannerStmt(query, args)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	if err := c.interceptors.beforeQuery(ctx, &ss); err != nil {
+		return spanner.Statement{}, err
+	}
+	return ss, nil
+}
+
+// interceptRows calls the AfterQuery hooks when the rows of
+// the query ss are done, or right away if the query failed.
+func (c *conn) interceptRows(ctx context.Context, ss spanner.Statement, r *rows, err error) (*rows, error) {
+	if len(c.interceptors) == 0 {
+		return r, err
+	}
+	if err != nil {
+		c.interceptors.afterQuery(ctx, ss, 0, err)
+		return nil, err
+	}
+	r.after = func(rowsReturned int64, err error) {
+		c.interceptors.afterQuery(ctx, ss, rowsReturned, err)
+	}
+	return r, nil
+}
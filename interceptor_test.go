@@ -0,0 +1,110 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestInterceptorsOrder(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	hook := func(name string) Interceptor {
+		return Interceptor{
+			BeforeQuery: func(ctx context.Context, stmt *spanner.Statement) error {
+				calls = append(calls, "before "+name)
+				stmt.SQL += " /* " + name + " */"
+				return nil
+			},
+			AfterQuery: func(ctx context.Context, stmt spanner.Statement, rowsReturned int64, err error) {
+				calls = append(calls, "after "+name)
+			},
+		}
+	}
+	is := interceptors{hook("a"), {}, hook("b")}
+
+	stmt := spanner.Statement{SQL: "SELECT 1"}
+	if err := is.beforeQuery(ctx, &stmt); err != nil {
+		t.Fatal(err)
+	}
+	is.afterQuery(ctx, stmt, 1, nil)
+
+	if got, want := stmt.SQL, "SELECT 1 /* a */ /* b */"; got != want {
+		t.Errorf("statement: want: %q, got: %q", want, got)
+	}
+	want := []string{"before a", "before b", "after b", "after a"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls: want: %v, got: %v", want, calls)
+	}
+}
+
+func TestInterceptorsShortCircuit(t *testing.T) {
+	ctx := context.Background()
+	errDenied := errors.New("denied")
+	var stmts []string
+	var afterCalled bool
+	c := &conn{
+		defaults: defaultConnOptions(),
+		vars:     defaultConnOptions(),
+		interceptors: interceptors{{
+			BeforeQuery: func(ctx context.Context, stmt *spanner.Statement) error {
+				stmts = append(stmts, stmt.SQL)
+				if stmt.Params["tenant"] != "t1" {
+					return errDenied
+				}
+				return nil
+			},
+			AfterQuery: func(ctx context.Context, stmt spanner.Statement, rowsReturned int64, err error) {
+				afterCalled = true
+			},
+			BeforeBegin: func(ctx context.Context, opts driver.TxOptions) error {
+				return errDenied
+			},
+		}},
+	}
+
+	_, err := c.QueryContext(ctx, "SELECT * FROM tweets WHERE tenant = @tenant",
+		[]driver.NamedValue{{Name: "tenant", Ordinal: 1, Value: "t2"}})
+	if !errors.Is(err, errDenied) {
+		t.Errorf("QueryContext: want: %v, got: %v", errDenied, err)
+	}
+	_, err = c.QueryContext(ctx, "EXPLAIN SELECT * FROM tweets WHERE tenant = 't1'", nil)
+	if !errors.Is(err, errDenied) {
+		t.Errorf("EXPLAIN: want: %v, got: %v", errDenied, err)
+	}
+	if want := []string{"SELECT * FROM tweets WHERE tenant = @tenant", "SELECT * FROM tweets WHERE tenant = 't1'"}; !reflect.DeepEqual(stmts, want) {
+		t.Errorf("intercepted statements: want: %q, got: %q", want, stmts)
+	}
+	if afterCalled {
+		t.Error("AfterQuery is called for short-circuited queries")
+	}
+
+	// Client statements aren't intercepted.
+	if _, err := c.ExecContext(ctx, "SET STATEMENT_TAG = 'app=timeline'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.BeginTx(ctx, driver.TxOptions{}); !errors.Is(err, errDenied) {
+		t.Errorf("BeginTx: want: %v, got: %v", errDenied, err)
+	}
+	if c.inTransaction() {
+		t.Error("short-circuited BeginTx started a transaction")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import "strings"
+
+// StatementKind is the kind of a GoogleSQL statement.
+type StatementKind int
+
+const (
+	// UnknownStatement is a statement that cannot be classified.
+	UnknownStatement StatementKind = iota
+
+	// QueryStatement is a query that returns rows.
+	QueryStatement
+
+	// DMLStatement is an INSERT, UPDATE or DELETE statement.
+	DMLStatement
+
+	// DMLReturningStatement is a DML statement with a THEN
+	// RETURN clause, which returns the modified rows.
+	DMLReturningStatement
+
+	// DDLStatement is a data definition language statement.
+	DDLStatement
+)
+
+func (k StatementKind) String() string {
+	switch k {
+	case QueryStatement:
+		return "query"
+	case DMLStatement:
+		return "DML"
+	case DMLReturningStatement:
+		return "DML with THEN RETURN"
+	case DDLStatement:
+		return "DDL"
+	}
+	return "unknown"
+}
+
+var statementKeywords = map[string]StatementKind{
+	"SELECT": QueryStatement,
+	"WITH":   QueryStatement,
+	"GRAPH":  QueryStatement,
+	"CALL":   QueryStatement,
+
+	"INSERT": DMLStatement,
+	"UPDATE": DMLStatement,
+	"DELETE": DMLStatement,
+
+	"CREATE":  DDLStatement,
+	"ALTER":   DDLStatement,
+	"DROP":    DDLStatement,
+	"RENAME":  DDLStatement,
+	"GRANT":   DDLStatement,
+	"REVOKE":  DDLStatement,
+	"ANALYZE": DDLStatement,
+}
+
+// Classify returns the kind of the statement q from its
+// first keyword. Comments and statement hints are skipped.
+func Classify(q string) (StatementKind, error) {
+	tokens, err := Tokenize(q)
+	if err != nil {
+		return UnknownStatement, err
+	}
+	var words []Token
+	for _, t := range tokens {
+		if t.Kind != CommentToken && t.Kind != HintToken {
+			words = append(words, t)
+		}
+	}
+	if len(words) == 0 {
+		return UnknownStatement, nil
+	}
+	first := words[0]
+	if first.Kind == SymbolToken && first.Text == "(" {
+		return QueryStatement, nil // parenthesized query
+	}
+	if first.Kind != WordToken {
+		return UnknownStatement, nil
+	}
+	kind := statementKeywords[strings.ToUpper(first.Text)]
+	if kind == DMLStatement && hasThenReturn(words) {
+		return DMLReturningStatement, nil
+	}
+	return kind, nil
+}
+
+func hasThenReturn(words []Token) bool {
+	for i := 1; i < len(words); i++ {
+		prev, t := words[i-1], words[i]
+		if prev.Kind == WordToken && t.Kind == WordToken &&
+			strings.EqualFold(prev.Text, "THEN") && strings.EqualFold(t.Text, "RETURN") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		input string
+		want  StatementKind
+	}{
+		{input: "SELECT * FROM tweets", want: QueryStatement},
+		{input: "select 1", want: QueryStatement},
+		{input: "WITH t AS (SELECT 1) SELECT * FROM t", want: QueryStatement},
+		{input: "(SELECT 1) UNION ALL (SELECT 2)", want: QueryStatement},
+		{input: "@{USE_ADDITIONAL_PARALLELISM=TRUE} SELECT * FROM tweets", want: QueryStatement},
+		{input: "-- comment\n/* comment */ SELECT 1", want: QueryStatement},
+		{input: "INSERT INTO tweets (id) VALUES (1)", want: DMLStatement},
+		{input: "UPDATE tweets SET text = 'THEN RETURN' WHERE id = 1", want: DMLStatement},
+		{input: "DELETE FROM tweets WHERE true", want: DMLStatement},
+		{input: "INSERT INTO tweets (id) VALUES (1) THEN RETURN id", want: DMLReturningStatement},
+		{input: "update tweets set likes = likes + 1 where true then return *", want: DMLReturningStatement},
+		{input: "CREATE TABLE tweets (id INT64) PRIMARY KEY (id)", want: DDLStatement},
+		{input: "ALTER TABLE tweets ADD COLUMN text STRING(MAX)", want: DDLStatement},
+		{input: "DROP INDEX TweetsByLikes", want: DDLStatement},
+		{input: "", want: UnknownStatement},
+		{input: "-- only a comment", want: UnknownStatement},
+		{input: "MERGE INTO tweets", want: UnknownStatement},
+	}
+
+	for _, tc := range tests {
+		got, err := Classify(tc.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: want: %v, got: %v", tc.input, tc.want, got)
+		}
+	}
+}
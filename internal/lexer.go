@@ -0,0 +1,217 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TokenKind is the kind of a lexical token.
+type TokenKind int
+
+const (
+	// WordToken is a keyword, an unquoted identifier, a number
+	// or a system variable.
+	WordToken TokenKind = iota + 1
+
+	// ParamToken is a named parameter reference such as @id.
+	ParamToken
+
+	// PositionalParamToken is a ? or $n placeholder. They are
+	// not valid GoogleSQL and are rewritten by the driver.
+	PositionalParamToken
+
+	// StringToken is a string or bytes literal, including
+	// triple-quoted, raw and bytes literals.
+	StringToken
+
+	// QuotedIdentToken is an identifier quoted with backticks.
+	QuotedIdentToken
+
+	// CommentToken is a --, # or /* */ comment.
+	CommentToken
+
+	// HintToken is a statement, table or join hint such
+	// as @{FORCE_INDEX=TweetsByLikes}.
+	HintToken
+
+	// SymbolToken is any other character.
+	SymbolToken
+)
+
+// Token is a lexical token of a GoogleSQL statement.
+type Token struct {
+	Kind TokenKind
+
+	// Pos and End are the byte offsets of
+	// the token in the statement.
+	Pos, End int
+
+	// Text is the text of the token.
+	Text string
+}
+
+// Tokenize splits the GoogleSQL statement q into tokens.
+// Whitespace is not returned as tokens. String literals,
+// quoted identifiers, comments and hints are returned as
+// single tokens, so parameter references are only reported
+// where Spanner would treat them as such.
+func Tokenize(q string) ([]Token, error) {
+	var tokens []Token
+	for i := 0; i < len(q); {
+		c := q[i]
+		var kind TokenKind
+		var end int
+		var err error
+		switch {
+		case isSpace(c):
+			i++
+			continue
+		case c == '\'' || c == '"':
+			kind = StringToken
+			end, err = scanString(q, i)
+			// Merge the r, b, rb and br prefixes of
+			// raw and bytes literals into the literal.
+			if n := len(tokens); n > 0 {
+				if prev := tokens[n-1]; prev.Kind == WordToken && prev.End == i && isLiteralPrefix(prev.Text) {
+					tokens = tokens[:n-1]
+					i = prev.Pos
+				}
+			}
+		case c == '`':
+			kind = QuotedIdentToken
+			end, err = scanQuoted(q, i, "`", "quoted identifier")
+		case c == '#', c == '-' && strings.HasPrefix(q[i:], "--"):
+			kind = CommentToken
+			end = scanLineComment(q, i)
+		case c == '/' && strings.HasPrefix(q[i:], "/*"):
+			kind = CommentToken
+			end, err = scanBlockComment(q, i)
+		case c == '@' && strings.HasPrefix(q[i:], "@{"):
+			kind = HintToken
+			end, err = scanHint(q, i)
+		case c == '@' && strings.HasPrefix(q[i:], "@@"):
+			// System variables such as @@optimizer_version.
+			kind = WordToken
+			end = scanWord(q, i+2)
+		case c == '@' && i+1 < len(q) && isIdentStart(q[i+1]):
+			kind = ParamToken
+			end = scanWord(q, i+1)
+		case c == '?':
+			kind = PositionalParamToken
+			end = i + 1
+		case c == '$' && i+1 < len(q) && isDigit(q[i+1]):
+			kind = PositionalParamToken
+			end = scanWord(q, i+1)
+		case isWordChar(c):
+			kind = WordToken
+			end = scanWord(q, i)
+		default:
+			kind = SymbolToken
+			end = i + 1
+		}
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, Token{Kind: kind, Pos: i, End: end, Text: q[i:end]})
+		i = end
+	}
+	return tokens, nil
+}
+
+// scanString returns the end of the string literal starting
+// at q[i]. Backslashes escape the following character, both
+// in regular and raw literals.
+func scanString(q string, i int) (int, error) {
+	delim := q[i : i+1]
+	if triple := strings.Repeat(delim, 3); strings.HasPrefix(q[i:], triple) {
+		delim = triple
+	}
+	return scanQuoted(q, i, delim, "string literal")
+}
+
+func scanQuoted(q string, i int, delim, what string) (int, error) {
+	for j := i + len(delim); j < len(q); j++ {
+		switch {
+		case q[j] == '\\':
+			j++
+		case strings.HasPrefix(q[j:], delim):
+			return j + len(delim), nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated %s at offset %d", what, i)
+}
+
+func scanLineComment(q string, i int) int {
+	if j := strings.IndexByte(q[i:], '\n'); j >= 0 {
+		return i + j + 1
+	}
+	return len(q)
+}
+
+func scanBlockComment(q string, i int) (int, error) {
+	if j := strings.Index(q[i+2:], "*/"); j >= 0 {
+		return i + 2 + j + 2, nil
+	}
+	return 0, fmt.Errorf("unterminated comment at offset %d", i)
+}
+
+func scanHint(q string, i int) (int, error) {
+	for j := i + 2; j < len(q); j++ {
+		switch q[j] {
+		case '\'', '"':
+			end, err := scanString(q, j)
+			if err != nil {
+				return 0, err
+			}
+			j = end - 1
+		case '}':
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated hint at offset %d", i)
+}
+
+func scanWord(q string, i int) int {
+	for i < len(q) && isWordChar(q[i]) {
+		i++
+	}
+	return i
+}
+
+func isLiteralPrefix(s string) bool {
+	switch strings.ToLower(s) {
+	case "r", "b", "rb", "br":
+		return true
+	}
+	return false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isWordChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c >= 0x80
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParamNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "no parameters",
+			input: "SELECT * FROM tweets",
+		},
+		{
+			name:  "parameters",
+			input: "SELECT * FROM tweets WHERE id = @id AND likes > @likes",
+			want:  []string{"id", "likes"},
+		},
+		{
+			name:  "repeated parameters",
+			input: "SELECT * FROM tweets WHERE a = @id OR b = @id",
+			want:  []string{"id"},
+		},
+		{
+			name:  "first appearance order",
+			input: "SELECT * FROM tweets WHERE a = @b OR b = @a OR c = @b",
+			want:  []string{"b", "a"},
+		},
+		{
+			name:  "parameter without spaces",
+			input: "SELECT * FROM tweets WHERE id=@id",
+			want:  []string{"id"},
+		},
+		{
+			name:  "single-quoted string",
+			input: "SELECT * FROM users WHERE email = 'jbd@google.com' AND id = @id",
+			want:  []string{"id"},
+		},
+		{
+			name:  "double-quoted string",
+			input: `SELECT * FROM users WHERE email = "jbd@google.com" AND id = @id`,
+			want:  []string{"id"},
+		},
+		{
+			name:  "escaped quotes",
+			input: `SELECT * FROM users WHERE a = 'it\'s @a' AND b = "\"@b\"" AND c = @c`,
+			want:  []string{"c"},
+		},
+		{
+			name:  "triple-quoted strings",
+			input: "SELECT * FROM users WHERE a = '''it's @a''' AND b = \"\"\"\n@b \"\n\"\"\" AND c = @c",
+			want:  []string{"c"},
+		},
+		{
+			name:  "raw and bytes literals",
+			input: `SELECT * FROM users WHERE a = r'\d+@a' AND b = b"@b" AND c = RB'''@c''' AND d = @d`,
+			want:  []string{"d"},
+		},
+		{
+			name:  "quoted identifiers",
+			input: "SELECT `col@a` FROM `my@table` WHERE b = @b",
+			want:  []string{"b"},
+		},
+		{
+			name:  "comments",
+			input: "SELECT * -- @a\nFROM users # @b\nWHERE /* @c\n@d */ e = @e",
+			want:  []string{"e"},
+		},
+		{
+			name:  "hints",
+			input: "@{USE_ADDITIONAL_PARALLELISM=TRUE} SELECT * FROM tweets@{FORCE_INDEX=TweetsByLikes} WHERE likes > @likes",
+			want:  []string{"likes"},
+		},
+		{
+			name:  "at signs",
+			input: "SELECT @@version, '@' || @a",
+			want:  []string{"a"},
+		},
+		{
+			name:    "unterminated string",
+			input:   "SELECT * FROM users WHERE email = 'jbd@google.com",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated triple-quoted string",
+			input:   "SELECT '''abc'' FROM users",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated comment",
+			input:   "SELECT * FROM users /* WHERE id = @id",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated hint",
+			input:   "SELECT * FROM users@{FORCE_INDEX=_BASE_TABLE WHERE id = @id",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := ParamNames(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%s: want: %q, got: %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	q := "SELECT r'a' FROM t@{FORCE_INDEX=i} WHERE a = @a -- done"
+	want := []Token{
+		{Kind: WordToken, Pos: 0, End: 6, Text: "SELECT"},
+		{Kind: StringToken, Pos: 7, End: 11, Text: "r'a'"},
+		{Kind: WordToken, Pos: 12, End: 16, Text: "FROM"},
+		{Kind: WordToken, Pos: 17, End: 18, Text: "t"},
+		{Kind: HintToken, Pos: 18, End: 34, Text: "@{FORCE_INDEX=i}"},
+		{Kind: WordToken, Pos: 35, End: 40, Text: "WHERE"},
+		{Kind: WordToken, Pos: 41, End: 42, Text: "a"},
+		{Kind: SymbolToken, Pos: 43, End: 44, Text: "="},
+		{Kind: ParamToken, Pos: 45, End: 47, Text: "@a"},
+		{Kind: CommentToken, Pos: 48, End: 55, Text: "-- done"},
+	}
+	got, err := Tokenize(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want: %+v, got: %+v", want, got)
+	}
+}
+
+func TestRewritePositionalParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantN   int
+		wantErr bool
+	}{
+		{
+			name:  "no placeholders",
+			input: "SELECT * FROM tweets WHERE id = @id",
+			want:  "SELECT * FROM tweets WHERE id = @id",
+		},
+		{
+			name:  "question marks",
+			input: "INSERT INTO tweets (id, text) VALUES (?, ?)",
+			want:  "INSERT INTO tweets (id, text) VALUES (@p1, @p2)",
+			wantN: 2,
+		},
+		{
+			name:  "numbered placeholders",
+			input: "SELECT * FROM tweets WHERE a = $2 OR b = $1 OR c = $2",
+			want:  "SELECT * FROM tweets WHERE a = @p2 OR b = @p1 OR c = @p2",
+			wantN: 2,
+		},
+		{
+			name:  "literals and comments",
+			input: "SELECT '?', \"$1\", `?` FROM tweets -- ?\nWHERE id = ? /* $2 */",
+			want:  "SELECT '?', \"$1\", `?` FROM tweets -- ?\nWHERE id = @p1 /* $2 */",
+			wantN: 1,
+		},
+		{
+			name:    "mixed placeholders",
+			input:   "SELECT * FROM tweets WHERE a = ? OR b = $1",
+			wantErr: true,
+		},
+		{
+			name:    "mixed with named parameters",
+			input:   "SELECT * FROM tweets WHERE a = ? OR b = @b",
+			wantErr: true,
+		},
+		{
+			name:    "zero placeholder",
+			input:   "SELECT * FROM tweets WHERE a = $0",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		got, n, err := RewritePositionalParams(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if got != tc.want || n != tc.wantN {
+			t.Errorf("%s: want: %q, %d, got: %q, %d", tc.name, tc.want, tc.wantN, got, n)
+		}
+	}
+}
@@ -15,20 +15,113 @@
 package internal
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
+	"strconv"
+	"strings"
 )
 
-var namedValueParamNameRegex = regexp.MustCompile("@(\\w+)")
-
-func NamedValueParamNames(q string, n int) ([]string, error) {
-	var names []string
-	matches := namedValueParamNameRegex.FindAllStringSubmatch(q, n)
-	if m := len(matches); n != -1 && m < n {
-		return nil, fmt.Errorf("query has %d placeholders but %d arguments are provided", m, n)
+// ParamNames returns the distinct names of the parameters referenced
+// in q, in order of their first appearance. References in string
+// literals, quoted identifiers, comments and hints are ignored.
+func ParamNames(q string) ([]string, error) {
+	tokens, err := Tokenize(q)
+	if err != nil {
+		return nil, err
 	}
-	for _, m := range matches {
-		names = append(names, m[1])
+	var names []string
+	seen := make(map[string]bool)
+	for _, t := range tokens {
+		if t.Kind != ParamToken {
+			continue
+		}
+		name := t.Text[1:]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
 	return names, nil
 }
+
+// ReplaceNamedValueParams replaces the references to the parameters
+// named in exprs with the given SQL expressions.
+func ReplaceNamedValueParams(q string, exprs map[string]string) (string, error) {
+	tokens, err := Tokenize(q)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	var last int
+	for _, t := range tokens {
+		if t.Kind != ParamToken {
+			continue
+		}
+		if expr, ok := exprs[t.Text[1:]]; ok {
+			b.WriteString(q[last:t.Pos])
+			b.WriteString(expr)
+			last = t.End
+		}
+	}
+	b.WriteString(q[last:])
+	return b.String(), nil
+}
+
+// PositionalParamName returns the name of the parameter that
+// the nth positional placeholder is rewritten to, starting at 1.
+func PositionalParamName(n int) string {
+	return "p" + strconv.Itoa(n)
+}
+
+// RewritePositionalParams rewrites the ? and $n placeholders in q
+// into the named parameters @p1 to @pN, as GoogleSQL only supports
+// named parameters. ? placeholders are numbered in the order of
+// appearance and $n placeholders are rewritten to @pn. It returns
+// the rewritten statement and the number of positional parameters,
+// which is zero if q doesn't have any positional placeholders.
+func RewritePositionalParams(q string) (string, int, error) {
+	tokens, err := Tokenize(q)
+	if err != nil {
+		return "", 0, err
+	}
+	var b strings.Builder
+	var last, n int
+	var hasNamed, hasQuestion, hasDollar bool
+	for _, t := range tokens {
+		if t.Kind == ParamToken {
+			hasNamed = true
+		}
+		if t.Kind != PositionalParamToken {
+			continue
+		}
+
+		var i int
+		if t.Text == "?" {
+			hasQuestion = true
+			n++
+			i = n
+		} else {
+			hasDollar = true
+			if i, err = strconv.Atoi(t.Text[1:]); err != nil || i < 1 {
+				return "", 0, fmt.Errorf("invalid placeholder %s at offset %d", t.Text, t.Pos)
+			}
+			if i > n {
+				n = i
+			}
+		}
+		b.WriteString(q[last:t.Pos])
+		b.WriteString("@" + PositionalParamName(i))
+		last = t.End
+	}
+	if hasQuestion && hasDollar {
+		return "", 0, errors.New("query cannot mix ? and $n placeholders")
+	}
+	if n > 0 && hasNamed {
+		return "", 0, errors.New("query cannot mix named parameters and positional placeholders")
+	}
+	if n == 0 {
+		return q, 0, nil
+	}
+	b.WriteString(q[last:])
+	return b.String(), n, nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import "strings"
+
+// RedactLiterals replaces the string, bytes and number literals
+// of q with ?, so that the statement can be recorded without the
+// values it contains. Comments are removed since they can contain
+// anything. An empty string is returned if q cannot be tokenized.
+func RedactLiterals(q string) string {
+	tokens, err := Tokenize(q)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	var last int
+	for _, t := range tokens {
+		var repl string
+		switch {
+		case t.Kind == StringToken:
+			repl = "?"
+		case t.Kind == WordToken && isDigit(t.Text[0]):
+			repl = "?"
+		case t.Kind == CommentToken:
+			repl = ""
+		default:
+			continue
+		}
+		b.WriteString(q[last:t.Pos])
+		b.WriteString(repl)
+		last = t.End
+	}
+	b.WriteString(q[last:])
+	return strings.TrimSpace(b.String())
+}
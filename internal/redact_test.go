@@ -0,0 +1,36 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import "testing"
+
+func TestRedactLiterals(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "SELECT * FROM tweets WHERE id = @id", want: "SELECT * FROM tweets WHERE id = @id"},
+		{input: "SELECT * FROM tweets WHERE text = 'secret' AND likes > 10", want: "SELECT * FROM tweets WHERE text = ? AND likes > ?"},
+		{input: "INSERT INTO t (b) VALUES (b'\\x00'), (r\"raw\")", want: "INSERT INTO t (b) VALUES (?), (?)"},
+		{input: "SELECT t2 FROM `t1` -- user@example.com\n", want: "SELECT t2 FROM `t1`"},
+		{input: "SELECT 'unterminated", want: ""},
+	}
+
+	for _, tc := range tests {
+		if got := RedactLiterals(tc.input); got != tc.want {
+			t.Errorf("%q: want: %q, got: %q", tc.input, tc.want, got)
+		}
+	}
+}
@@ -17,6 +17,7 @@ package internal
 import (
 	"context"
 	"errors"
+	"time"
 
 	"cloud.google.com/go/spanner"
 )
@@ -31,37 +32,72 @@ type RWConnector struct {
 	ExecIn  chan *RWExecMessage
 	ExecOut chan *RWExecMessage
 
+	BufferWriteIn  chan *RWBufferWriteMessage
+	BufferWriteOut chan *RWBufferWriteMessage
+
+	BatchUpdateIn  chan *RWBatchUpdateMessage
+	BatchUpdateOut chan *RWBatchUpdateMessage
+
 	RollbackIn chan struct{}
 	CommitIn   chan struct{}
 	Errors     chan error // only for starting, commit and rollback
 
 	Ready chan struct{}
+
+	// CommitTimestamp is set when the transaction
+	// commits, before the result is sent to Errors.
+	CommitTimestamp time.Time
+
+	// Attempts is the number of times the client ran the
+	// transaction, set before the result is sent to Errors.
+	Attempts int
 }
 
-func NewRWConnector(ctx context.Context, c *spanner.Client) *RWConnector {
+func NewRWConnector(ctx context.Context, c *spanner.Client, opts spanner.TransactionOptions) *RWConnector {
 	connector := &RWConnector{
-		QueryIn:    make(chan *RWQueryMessage),
-		QueryOut:   make(chan *RWQueryMessage),
-		ExecIn:     make(chan *RWExecMessage),
-		ExecOut:    make(chan *RWExecMessage),
+		QueryIn:  make(chan *RWQueryMessage),
+		QueryOut: make(chan *RWQueryMessage),
+		ExecIn:   make(chan *RWExecMessage),
+		ExecOut:  make(chan *RWExecMessage),
+
+		BufferWriteIn:  make(chan *RWBufferWriteMessage),
+		BufferWriteOut: make(chan *RWBufferWriteMessage),
+
+		BatchUpdateIn:  make(chan *RWBatchUpdateMessage),
+		BatchUpdateOut: make(chan *RWBatchUpdateMessage),
+
 		RollbackIn: make(chan struct{}),
 		CommitIn:   make(chan struct{}),
 		Errors:     make(chan error),
 		Ready:      make(chan struct{}),
 	}
 
+	var attempts int
 	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		attempts++
+		if attempts > 1 {
+			// The client retries the function if Spanner aborts the
+			// transaction but the statements have already been executed
+			// and their results returned, they cannot be replayed.
+			return ErrAbortedTransaction
+		}
 		connector.Ready <- struct{}{}
 		for {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
 			case msg := <-connector.QueryIn:
-				msg.It = tx.Query(msg.Ctx, msg.Stmt)
+				msg.It = tx.QueryWithOptions(msg.Ctx, msg.Stmt, msg.Opts)
 				connector.QueryOut <- msg
 			case msg := <-connector.ExecIn:
-				msg.Rows, msg.Error = tx.Update(msg.Ctx, msg.Stmt)
+				msg.Rows, msg.Error = tx.UpdateWithOptions(msg.Ctx, msg.Stmt, msg.Opts)
 				connector.ExecOut <- msg
+			case msg := <-connector.BufferWriteIn:
+				msg.Error = tx.BufferWrite(msg.Mutations)
+				connector.BufferWriteOut <- msg
+			case msg := <-connector.BatchUpdateIn:
+				msg.Rows, msg.Error = tx.BatchUpdateWithOptions(msg.Ctx, msg.Stmts, msg.Opts)
+				connector.BatchUpdateOut <- msg
 			case <-connector.RollbackIn:
 				return ErrAborted
 			case <-connector.CommitIn:
@@ -70,25 +106,48 @@ func NewRWConnector(ctx context.Context, c *spanner.Client) *RWConnector {
 		}
 	}
 	go func() {
-		_, err := c.ReadWriteTransaction(ctx, fn)
+		resp, err := c.ReadWriteTransactionWithOptions(ctx, fn, opts)
+		connector.CommitTimestamp = resp.CommitTs
+		connector.Attempts = attempts
 		connector.Errors <- err
 	}()
 	return connector
 }
 
 type RWQueryMessage struct {
-	Ctx  context.Context   // in
-	Stmt spanner.Statement // in
+	Ctx  context.Context      // in
+	Stmt spanner.Statement    // in
+	Opts spanner.QueryOptions // in
 
 	It *spanner.RowIterator // out
 }
 
 type RWExecMessage struct {
-	Ctx  context.Context   // in
-	Stmt spanner.Statement // in
+	Ctx  context.Context      // in
+	Stmt spanner.Statement    // in
+	Opts spanner.QueryOptions // in
 
 	Rows  int64 // out
 	Error error // out
 }
 
+type RWBufferWriteMessage struct {
+	Mutations []*spanner.Mutation // in
+
+	Error error // out
+}
+
+type RWBatchUpdateMessage struct {
+	Ctx   context.Context      // in
+	Stmts []spanner.Statement  // in
+	Opts  spanner.QueryOptions // in
+
+	Rows  []int64 // out
+	Error error   // out
+}
+
 var ErrAborted = errors.New("aborted")
+
+// ErrAbortedTransaction is returned if Spanner aborts a
+// read-write transaction that cannot be retried internally.
+var ErrAbortedTransaction = errors.New("transaction was aborted by Spanner, retry the transaction")
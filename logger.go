@@ -0,0 +1,99 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+)
+
+// Logger logs the statements executed and the mutations applied by
+// the driver. It is implemented by *slog.Logger. args are
+// alternating keys and values, as with slog. The methods are
+// called concurrently.
+type Logger interface {
+	// InfoContext logs a statement that succeeded.
+	InfoContext(ctx context.Context, msg string, args ...interface{})
+
+	// WarnContext logs a statement that succeeded but took
+	// longer than the slow statement threshold.
+	WarnContext(ctx context.Context, msg string, args ...interface{})
+
+	// ErrorContext logs a statement that failed.
+	ErrorContext(ctx context.Context, msg string, args ...interface{})
+}
+
+// logStatement logs the statement of op, or the number of
+// mutations it applies, if op executes a statement or applies
+// mutations and the driver has a logger. The duration of a query
+// includes reading its rows.
+func (op *operation) logStatement(d time.Duration, err error) {
+	in := op.inst
+	if in == nil || in.logger == nil || !op.isStatement && !op.isMutations {
+		return
+	}
+	what := "statement"
+	args := []interface{}{"op", op.name}
+	if op.isMutations {
+		what = "mutations"
+		args = append(args, "mutations", op.mutations)
+	} else {
+		args = append(args,
+			"statement", in.statementText(op.query),
+			"params", in.params(op.query, op.args))
+	}
+	args = append(args, "duration", d)
+	if op.rowsKey != "" {
+		args = append(args, op.rowsKey, op.rows)
+	}
+	switch {
+	case err != nil:
+		in.logger.ErrorContext(op.ctx, "spanner "+what+" failed", append(args, "error", err)...)
+	case in.slowThreshold > 0 && d >= in.slowThreshold:
+		in.logger.WarnContext(op.ctx, "slow spanner "+what, args...)
+	default:
+		in.logger.InfoContext(op.ctx, "spanner "+what, args...)
+	}
+}
+
+// params returns the arguments of a statement by the name of the
+// parameter they are bound to. Arguments that can't be bound are
+// logged by name or as p<ordinal>. Their values are redacted if
+// literals are removed from the recorded statements.
+func (in *instrumentation) params(query string, args []driver.NamedValue) map[string]interface{} {
+	var params map[string]interface{}
+	if p, err := parseStatement(query); err == nil {
+		params, _ = bindParams(p.names, args)
+	}
+	if params == nil {
+		params = make(map[string]interface{}, len(args))
+		for _, arg := range args {
+			name := arg.Name
+			if name == "" {
+				name = internal.PositionalParamName(arg.Ordinal)
+			}
+			params[name] = arg.Value
+		}
+	}
+	if in.redact {
+		for name := range params {
+			params[name] = "?"
+		}
+	}
+	return params
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type logEntry struct {
+	level string
+	msg   string
+	attrs map[string]interface{}
+}
+
+type fakeLogger struct {
+	entries []logEntry
+}
+
+func (l *fakeLogger) log(level, msg string, args []interface{}) {
+	attrs := make(map[string]interface{})
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs[args[i].(string)] = args[i+1]
+	}
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, attrs: attrs})
+}
+
+func (l *fakeLogger) InfoContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("INFO", msg, args)
+}
+
+func (l *fakeLogger) WarnContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("WARN", msg, args)
+}
+
+func (l *fakeLogger) ErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	l.log("ERROR", msg, args)
+}
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		name          string
+		redact        bool
+		slowThreshold time.Duration
+		query         string
+		args          []driver.NamedValue
+		wantLevel     string
+		wantStatement string
+		wantParams    map[string]interface{}
+	}{
+		{
+			name:          "info",
+			query:         "SET STATEMENT_TAG = 'app=timeline'",
+			wantLevel:     "INFO",
+			wantStatement: "SET STATEMENT_TAG = 'app=timeline'",
+			wantParams:    map[string]interface{}{},
+		},
+		{
+			name:          "error",
+			query:         "SET FOO = 1",
+			wantLevel:     "ERROR",
+			wantStatement: "SET FOO = 1",
+			wantParams:    map[string]interface{}{},
+		},
+		{
+			name:          "slow",
+			slowThreshold: time.Nanosecond,
+			query:         "SET STATEMENT_TAG = 'app=timeline'",
+			wantLevel:     "WARN",
+			wantStatement: "SET STATEMENT_TAG = 'app=timeline'",
+			wantParams:    map[string]interface{}{},
+		},
+		{
+			name:          "params",
+			query:         "SET FOO = @foo || @bar",
+			args:          []driver.NamedValue{{Name: "foo", Ordinal: 1, Value: "bar"}, {Ordinal: 2, Value: int64(1)}},
+			wantLevel:     "ERROR",
+			wantStatement: "SET FOO = @foo || @bar",
+			wantParams:    map[string]interface{}{"foo": "bar", "bar": int64(1)},
+		},
+		{
+			name:          "positional params",
+			query:         "SET FOO = ? || ?",
+			args:          []driver.NamedValue{{Ordinal: 1, Value: "bar"}, {Ordinal: 2, Value: int64(1)}},
+			wantLevel:     "ERROR",
+			wantStatement: "SET FOO = ? || ?",
+			wantParams:    map[string]interface{}{"p1": "bar", "p2": int64(1)},
+		},
+		{
+			name:          "unbound params",
+			query:         "SET FOO = @foo",
+			args:          []driver.NamedValue{{Name: "foo", Ordinal: 1, Value: "bar"}, {Ordinal: 2, Value: int64(1)}},
+			wantLevel:     "ERROR",
+			wantStatement: "SET FOO = @foo",
+			wantParams:    map[string]interface{}{"foo": "bar", "p2": int64(1)},
+		},
+		{
+			name:          "redacted",
+			redact:        true,
+			query:         "SET FOO = 'bar'",
+			args:          []driver.NamedValue{{Name: "foo", Ordinal: 1, Value: "bar"}},
+			wantLevel:     "ERROR",
+			wantStatement: "SET FOO = ?",
+			wantParams:    map[string]interface{}{"foo": "?"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &fakeLogger{}
+			d := &Driver{Logger: l, SlowStatementThreshold: tt.slowThreshold, RedactStatements: tt.redact}
+			c := &conn{
+				inst:     newInstrumentation(d, "projects/p/instances/i/databases/d"),
+				defaults: defaultConnOptions(),
+				vars:     defaultConnOptions(),
+			}
+			c.ExecContext(context.Background(), tt.query, tt.args)
+
+			if len(l.entries) != 1 {
+				t.Fatalf("want 1 log entry, got: %d", len(l.entries))
+			}
+			e := l.entries[0]
+			if e.level != tt.wantLevel {
+				t.Errorf("level: want: %v, got: %v", tt.wantLevel, e.level)
+			}
+			if got := e.attrs["statement"]; got != tt.wantStatement {
+				t.Errorf("statement: want: %q, got: %q", tt.wantStatement, got)
+			}
+			if got := e.attrs["params"]; !reflect.DeepEqual(got, tt.wantParams) {
+				t.Errorf("params: want: %v, got: %v", tt.wantParams, got)
+			}
+			if _, ok := e.attrs["error"]; ok != (tt.wantLevel == "ERROR") {
+				t.Errorf("error: want: %v, got: %v", tt.wantLevel == "ERROR", ok)
+			}
+		})
+	}
+}
+
+func TestLoggerMutations(t *testing.T) {
+	l := &fakeLogger{}
+	in := newInstrumentation(&Driver{Logger: l}, "projects/p/instances/i/databases/d")
+	_, op := in.startMutations(context.Background(), 2)
+	op.end(nil)
+
+	if len(l.entries) != 1 {
+		t.Fatalf("want 1 log entry, got: %d", len(l.entries))
+	}
+	e := l.entries[0]
+	if e.level != "INFO" || e.msg != "spanner mutations" {
+		t.Errorf("entry: want: INFO spanner mutations, got: %v %v", e.level, e.msg)
+	}
+	if got := e.attrs["op"]; got != "ApplyMutations" {
+		t.Errorf("op: want: ApplyMutations, got: %v", got)
+	}
+	if got := e.attrs["mutations"]; got != 2 {
+		t.Errorf("mutations: want: 2, got: %v", got)
+	}
+	for _, key := range []string{"statement", "params"} {
+		if got, ok := e.attrs[key]; ok {
+			t.Errorf("%s: want none, got: %v", key, got)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"go.opencensus.io/stats/view"
+)
+
+// Metrics receives the measurements of the driver. It can be
+// implemented with OpenTelemetry, Prometheus or any other metrics
+// library. The methods are called concurrently.
+type Metrics interface {
+	// RecordLatency records the duration of an operation of the
+	// driver: Open, BeginTx, ExecContext, QueryContext,
+	// ApplyMutations, Commit or Rollback. The duration of QueryContext includes reading the
+	// rows. err is the error of the operation, if any.
+	RecordLatency(ctx context.Context, op string, d time.Duration, err error)
+
+	// AddCount adds delta to the counter named name, one of
+	// the Metric constants. Counters of open connections and
+	// active transactions also decrease.
+	AddCount(ctx context.Context, name string, delta int64)
+
+	// RecordGauge records the value of the session pool gauge named
+	// name, one of the MetricSessions constants. The labels identify
+	// the Spanner client the value belongs to: client_id, database,
+	// instance_id and, for MetricSessionsInPool, the type of the sessions.
+	RecordGauge(name string, value int64, labels map[string]string)
+}
+
+// The names of the counters and gauges recorded with Metrics.
+const (
+	MetricConnectionsOpen     = "spanner.connections.open"
+	MetricTransactionsActive  = "spanner.transactions.active"
+	MetricTransactionsAborted = "spanner.transactions.aborted"
+	MetricTransactionRetries  = "spanner.transactions.retries"
+	MetricRowsReturned        = "spanner.rows.returned"
+	MetricRowsAffected        = "spanner.rows.affected"
+
+	MetricSessionsOpen        = "spanner.sessions.open"
+	MetricSessionsMaxAllowed  = "spanner.sessions.max_allowed"
+	MetricSessionsInPool      = "spanner.sessions.in_pool"
+	MetricSessionsMaxInUse    = "spanner.sessions.max_in_use"
+	MetricSessionsGetTimeouts = "spanner.sessions.get_timeouts"
+	MetricSessionsAcquired    = "spanner.sessions.acquired"
+	MetricSessionsReleased    = "spanner.sessions.released"
+)
+
+// sessionGauges maps the OpenCensus views of the
+// session pool of the Spanner client to gauges.
+var sessionGauges = map[string]string{
+	spanner.OpenSessionCountView.Measure.Name():        MetricSessionsOpen,
+	spanner.MaxAllowedSessionsCountView.Measure.Name(): MetricSessionsMaxAllowed,
+	spanner.SessionsCountView.Measure.Name():           MetricSessionsInPool,
+	spanner.MaxInUseSessionsCountView.Measure.Name():   MetricSessionsMaxInUse,
+	spanner.GetSessionTimeoutsCountView.Measure.Name(): MetricSessionsGetTimeouts,
+	spanner.AcquiredSessionsCountView.Measure.Name():   MetricSessionsAcquired,
+	spanner.ReleasedSessionsCountView.Measure.Name():   MetricSessionsReleased,
+}
+
+// sessionStats exports the session pool statistics of the
+// Spanner clients to the Metrics of the drivers. The Spanner
+// client reports them with OpenCensus, at the OpenCensus
+// reporting period.
+var sessionStats = &sessionStatsExporter{drivers: make(map[*Driver]*statsRegistration)}
+
+type sessionStatsExporter struct {
+	once    sync.Once
+	err     error
+	mu      sync.Mutex
+	drivers map[*Driver]*statsRegistration
+}
+
+// statsRegistration is the registration of a driver
+// by its open connections.
+type statsRegistration struct {
+	metrics Metrics
+	conns   int
+}
+
+// register starts exporting the session pool statistics to the
+// metrics of the driver. Each connection of the driver registers
+// it, and unregisters it when it's closed.
+func (e *sessionStatsExporter) register(d *Driver) error {
+	e.once.Do(func() {
+		if e.err = spanner.EnableStatViews(); e.err == nil {
+			view.RegisterExporter(e)
+		}
+	})
+	if e.err != nil {
+		return e.err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.drivers[d]
+	if !ok {
+		r = &statsRegistration{metrics: d.Metrics}
+		e.drivers[d] = r
+	}
+	r.conns++
+	return nil
+}
+
+// unregister stops exporting the session pool statistics
+// to the driver once all its connections are closed.
+func (e *sessionStatsExporter) unregister(d *Driver) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	r, ok := e.drivers[d]
+	if !ok {
+		return
+	}
+	if r.conns--; r.conns == 0 {
+		delete(e.drivers, d)
+	}
+}
+
+// ExportView implements view.Exporter.
+func (e *sessionStatsExporter) ExportView(vd *view.Data) {
+	name, ok := sessionGauges[vd.View.Measure.Name()]
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, row := range vd.Rows {
+		var value int64
+		switch data := row.Data.(type) {
+		case *view.LastValueData:
+			value = int64(data.Value)
+		case *view.CountData:
+			value = data.Value
+		default:
+			continue
+		}
+		labels := make(map[string]string, len(row.Tags))
+		for _, t := range row.Tags {
+			labels[t.Key.Name()] = t.Value
+		}
+		for _, r := range e.drivers {
+			r.metrics.RecordGauge(name, value, labels)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	"go.opencensus.io/stats/view"
+)
+
+// countingMetrics records the counters and gauges of the driver.
+type countingMetrics struct {
+	mu     sync.Mutex
+	counts map[string]int64
+	gauges map[string]int64
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{counts: make(map[string]int64), gauges: make(map[string]int64)}
+}
+
+func (m *countingMetrics) RecordLatency(ctx context.Context, op string, d time.Duration, err error) {}
+
+func (m *countingMetrics) AddCount(ctx context.Context, name string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name] += delta
+}
+
+func (m *countingMetrics) RecordGauge(name string, value int64, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[name] = value
+}
+
+func (m *countingMetrics) count(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[name]
+}
+
+func (m *countingMetrics) gauge(name string) (int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.gauges[name]
+	return v, ok
+}
+
+func TestMetricsTransactionsAborted(t *testing.T) {
+	ctx := context.Background()
+	m := newCountingMetrics()
+	s, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{Metrics: m})
+	s.MustPutStatementResult(t, likeAll, &fakespanner.StatementResult{UpdateCount: 1})
+
+	// The transaction of the statement is retried.
+	s.PutCommitError(fakespanner.ErrAborted)
+	if _, err := db.ExecContext(ctx, likeAll); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.count(spannerdriver.MetricTransactionsAborted); got != 1 {
+		t.Errorf("aborted transactions: want: 1, got: %d", got)
+	}
+	if got := m.count(spannerdriver.MetricTransactionRetries); got != 1 {
+		t.Errorf("retried transactions: want: 1, got: %d", got)
+	}
+
+	// Explicit transactions aren't retried.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, likeAll); err != nil {
+		t.Fatal(err)
+	}
+	s.PutCommitError(fakespanner.ErrAborted)
+	tx.Commit()
+	if got := m.count(spannerdriver.MetricTransactionsAborted); got != 2 {
+		t.Errorf("aborted transactions: want: 2, got: %d", got)
+	}
+	if got := m.count(spannerdriver.MetricTransactionRetries); got != 1 {
+		t.Errorf("retried transactions: want: 1, got: %d", got)
+	}
+}
+
+func TestMetricsSessionStats(t *testing.T) {
+	view.SetReportingPeriod(10 * time.Millisecond)
+	defer view.SetReportingPeriod(10 * time.Second)
+
+	m := newCountingMetrics()
+	_, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{Metrics: m})
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if !spannerdriver.SessionStatsRegistered(m) {
+		t.Fatal("the session pool statistics aren't exported to the driver")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := m.gauge(spannerdriver.MetricSessionsMaxAllowed); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no session pool statistics")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The driver is unregistered once its connections are closed.
+	db.Close()
+	if spannerdriver.SessionStatsRegistered(m) {
+		t.Error("the driver is still registered after its connections are closed")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	"cloud.google.com/go/spanner"
+)
+
+// Execer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// ApplyMutations writes the mutations with e. If e is a *sql.Tx,
+// the mutations are buffered and applied when the transaction
+// commits. Otherwise, they are applied atomically in a new
+// read-write transaction.
+//
+// Example:
+//
+//	err := spannerdriver.ApplyMutations(ctx, tx,
+//		spanner.Insert("tweets", []string{"id", "text"}, []interface{}{id, text}),
+//		spanner.Delete("tweets", spanner.Key{oldID}),
+//	)
+func ApplyMutations(ctx context.Context, e Execer, ms ...*spanner.Mutation) error {
+	_, err := e.ExecContext(ctx, "", mutations(ms))
+	return err
+}
+
+// mutations is passed as an argument to ExecContext
+// by ApplyMutations.
+type mutations []*spanner.Mutation
+
+func mutationsArg(args []driver.NamedValue) ([]*spanner.Mutation, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	ms, ok := args[0].Value.(mutations)
+	return ms, ok
+}
+
+func (c *conn) CheckNamedValue(v *driver.NamedValue) error {
+	switch v.Value.(type) {
+	case mutations:
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// applyMutations applies the mutations passed to ExecContext
+// by ApplyMutations. It has no statement, so it's traced and
+// logged as an ApplyMutations operation of its own.
+func (c *conn) applyMutations(ctx context.Context, ms []*spanner.Mutation) (driver.Result, error) {
+	ctx, op := c.inst.startMutations(ctx, len(ms), c.transactionType())
+	d := newStatementDeadline(ctx, c.vars.statementTimeout)
+	defer d.done()
+	err := d.err(c.Apply(d.ctx, ms))
+	op.end(err)
+	if err != nil {
+		return nil, err
+	}
+	return &result{}, nil
+}
+
+func (c *conn) Apply(ctx context.Context, ms []*spanner.Mutation) error {
+	if err := c.errIfReplaying("mutations"); err != nil {
+		return err
+	}
+	if c.roTx != nil {
+		return errors.New("cannot write in read-only transaction")
+	}
+	if c.rwTx != nil {
+		return c.rwTx.BufferWrite(ms)
+	}
+	return c.runInNewRWTransaction(ctx, func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		return tx.BufferWrite(ms)
+	})
+}
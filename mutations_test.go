@@ -0,0 +1,160 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// commits returns the commit requests received by s.
+func commits(s *fakespanner.Server) []*sppb.CommitRequest {
+	var reqs []*sppb.CommitRequest
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.CommitRequest); ok {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func TestMutationsAutocommit(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+
+	err := spannerdriver.ApplyMutations(ctx, db,
+		spanner.Insert("tweets", []string{"id", "text"}, []interface{}{int64(1), "hello"}),
+		spanner.Delete("tweets", spanner.Key{int64(2)}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := commits(s)
+	if len(reqs) != 1 {
+		t.Fatalf("commits: want: 1, got: %d", len(reqs))
+	}
+	ms := reqs[0].Mutations
+	if len(ms) != 2 {
+		t.Fatalf("mutations: want: 2, got: %v", ms)
+	}
+	if insert := ms[0].GetInsert(); insert == nil || insert.Table != "tweets" || len(insert.Values) != 1 {
+		t.Errorf("mutation 0: want an insert of a tweet, got: %v", ms[0])
+	}
+	if del := ms[1].GetDelete(); del == nil || del.Table != "tweets" || len(del.KeySet.Keys) != 1 {
+		t.Errorf("mutation 1: want a delete of a tweet, got: %v", ms[1])
+	}
+}
+
+func TestMutationsInTransaction(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(1); i <= 2; i++ {
+		m := spanner.Insert("tweets", []string{"id", "text"}, []interface{}{i, "hello"})
+		if err := spannerdriver.ApplyMutations(ctx, tx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(commits(s)); n != 0 {
+		t.Fatalf("commits before Commit: want: 0, got: %d", n)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	reqs := commits(s)
+	if len(reqs) != 1 {
+		t.Fatalf("commits: want: 1, got: %d", len(reqs))
+	}
+	if ms := reqs[0].Mutations; len(ms) != 2 || ms[0].GetInsert() == nil || ms[1].GetInsert() == nil {
+		t.Errorf("mutations: want the 2 buffered inserts, got: %v", ms)
+	}
+
+	// Mutations are discarded when the transaction rolls back.
+	s.ClearRequests()
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spannerdriver.ApplyMutations(ctx, tx, spanner.Delete("tweets", spanner.AllKeys())); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(commits(s)); n != 0 {
+		t.Errorf("commits after Rollback: want: 0, got: %d", n)
+	}
+
+	// Read-only transactions can't write.
+	tx, err = db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err := spannerdriver.ApplyMutations(ctx, tx, spanner.Delete("tweets", spanner.AllKeys())); err == nil {
+		t.Error("ApplyMutations in a read-only transaction: want error")
+	}
+}
+
+func TestMutationsSpans(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	_, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{TracerProvider: tp})
+
+	err := spannerdriver.ApplyMutations(ctx, db,
+		spanner.Insert("tweets", []string{"id", "text"}, []interface{}{int64(1), "hello"}),
+		spanner.Delete("tweets", spanner.Key{int64(2)}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mutations have a span of their own, without a statement.
+	var found bool
+	for _, span := range recorder.Ended() {
+		switch span.Name() {
+		case "spanner.ExecContext":
+			t.Errorf("mutations were traced as a statement: %v", span.Attributes())
+		case "spanner.ApplyMutations":
+			found = true
+			for _, kv := range span.Attributes() {
+				switch kv.Key {
+				case "db.statement", "spanner.param_count":
+					t.Errorf("mutations span has attribute %s", kv.Key)
+				case "spanner.mutation_count":
+					if got := kv.Value.AsInt64(); got != 2 {
+						t.Errorf("mutation count: want: 2, got: %d", got)
+					}
+				}
+			}
+		}
+	}
+	if !found {
+		t.Error("no spanner.ApplyMutations span")
+	}
+}
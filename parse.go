@@ -0,0 +1,116 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+)
+
+// parsedStmt is the metadata of a statement
+// the driver needs to execute it.
+type parsedStmt struct {
+	// client is set if the statement is a client statement.
+	client *clientStmt
+
+	kind internal.StatementKind
+
+	// sql is the statement with its positional
+	// placeholders rewritten to named parameters.
+	sql string
+
+	// names are the distinct names of the parameters.
+	names []string
+}
+
+// parseStatement parses q, or returns the cached result
+// if q has been parsed before.
+func parseStatement(q string) (*parsedStmt, error) {
+	if p, ok := parsedStmts.get(q); ok {
+		return p, nil
+	}
+	sql, names, err := parseParams(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &parsedStmt{sql: sql, names: names}
+	if cs, ok := parseClientStmt(q); ok {
+		p.client = &cs
+	} else if p.kind, err = internal.Classify(q); err != nil {
+		return nil, err
+	}
+	parsedStmts.put(q, p)
+	return p, nil
+}
+
+// isClientStmt reports whether q is a client statement.
+func isClientStmt(q string) bool {
+	p, err := parseStatement(q)
+	return err == nil && p.client != nil
+}
+
+// parsedStmts caches the parsed statements by their
+// query string, shared by all connections.
+var parsedStmts = newStmtCache(1000)
+
+// stmtCache is a least recently used cache of parsed statements.
+type stmtCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List // of *stmtCacheEntry, most recently used first
+	items map[string]*list.Element
+}
+
+type stmtCacheEntry struct {
+	query string
+	p     *parsedStmt
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (c *stmtCache) get(q string) (*parsedStmt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[q]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return e.Value.(*stmtCacheEntry).p, true
+}
+
+func (c *stmtCache) put(q string, p *parsedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[q]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*stmtCacheEntry).p = p
+		return
+	}
+	c.items[q] = c.ll.PushFront(&stmtCacheEntry{query: q, p: p})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*stmtCacheEntry).query)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+)
+
+func TestParseStatement(t *testing.T) {
+	q := "SELECT * FROM tweets WHERE a = ? AND b = ?"
+	p, err := parseStatement(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &parsedStmt{
+		kind:  internal.QueryStatement,
+		sql:   "SELECT * FROM tweets WHERE a = @p1 AND b = @p2",
+		names: []string{"p1", "p2"},
+	}
+	if !reflect.DeepEqual(want, p) {
+		t.Errorf("want: %+v, got: %+v", want, p)
+	}
+	if cached, _ := parseStatement(q); cached != p {
+		t.Errorf("statement is not cached")
+	}
+
+	p, err = parseStatement("RUN BATCH")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.client == nil || p.client.kind != runBatchStmt {
+		t.Errorf("want client statement, got: %+v", p)
+	}
+}
+
+func TestStmtCache(t *testing.T) {
+	c := newStmtCache(2)
+	a, b, d := &parsedStmt{sql: "a"}, &parsedStmt{sql: "b"}, &parsedStmt{sql: "d"}
+	c.put("a", a)
+	c.put("b", b)
+	c.get("a") // b is now the least recently used
+	c.put("d", d)
+
+	if _, ok := c.get("b"); ok {
+		t.Errorf("b should have been evicted")
+	}
+	if got, _ := c.get("a"); got != a {
+		t.Errorf("want a, got: %+v", got)
+	}
+	if got, _ := c.get("d"); got != d {
+		t.Errorf("want d, got: %+v", got)
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// PartitionedQuery is a query that is split into partitions
+// which can be executed in parallel. See Conn.PartitionQuery.
+type PartitionedQuery struct {
+	// Partitions are the serialized partitions of the query.
+	// Each partition can be executed with ExecutePartition on
+	// any connection to the same database, including the
+	// connections of other processes.
+	Partitions []string
+
+	tx *spanner.BatchReadOnlyTransaction
+}
+
+// Close releases the read-only transaction the query has
+// been partitioned in. The partitions can no longer be
+// executed once the query is closed.
+func (pq *PartitionedQuery) Close(ctx context.Context) {
+	pq.tx.Cleanup(ctx)
+}
+
+// Queryer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// ExecutePartition executes a partition of a PartitionedQuery
+// with q and returns its results. It is equivalent to running
+// RUN PARTITION 'partition', which fails in a transaction.
+//
+// Example:
+//
+//	for _, p := range pq.Partitions {
+//		go func(p string) {
+//			rows, err := spannerdriver.ExecutePartition(ctx, db, p)
+//			...
+//		}(p)
+//	}
+func ExecutePartition(ctx context.Context, q Queryer, partition string) (*sql.Rows, error) {
+	return q.QueryContext(ctx, "RUN PARTITION '"+partition+"'")
+}
+
+// PartitionQuery partitions the query in a new read-only
+// transaction with the READ_ONLY_STALENESS of the connection,
+// which can't be a MIN_READ_TIMESTAMP or MAX_STALENESS bound.
+// Queries can't be partitioned in a transaction. The caller
+// needs to close the returned query once all partitions are
+// executed.
+func (c *conn) PartitionQuery(ctx context.Context, query string, args ...interface{}) (*PartitionedQuery, error) {
+	ss, tx, ps, err := c.partitionQuery(ctx, query, namedValues(args))
+	if err != nil {
+		return nil, err
+	}
+	c.interceptors.afterQuery(ctx, ss, 0, nil)
+	pq := &PartitionedQuery{tx: tx}
+	tid := tx.ID
+	for _, p := range ps {
+		token, err := encodePartition(tid, p)
+		if err != nil {
+			tx.Cleanup(ctx)
+			return nil, err
+		}
+		pq.Partitions = append(pq.Partitions, token)
+	}
+	return pq, nil
+}
+
+// partitionQuery partitions the query. If partitioning fails
+// after the BeforeQuery hooks, the AfterQuery hooks are called.
+func (c *conn) partitionQuery(ctx context.Context, query string, args []driver.NamedValue) (spanner.Statement, *spanner.BatchReadOnlyTransaction, []*spanner.Partition, error) {
+	if err := c.errIfReplaying("partitioned queries"); err != nil {
+		return spanner.Statement{}, nil, nil, err
+	}
+	if c.inTransaction() {
+		return spanner.Statement{}, nil, nil, errors.New("cannot partition a query in a transaction")
+	}
+	staleness := c.vars.readOnlyStaleness
+	if staleness.singleUse {
+		return spanner.Statement{}, nil, nil, fmt.Errorf("read-only staleness %s cannot be used to partition a query", staleness.text)
+	}
+	ss, err := c.prepareQuery(ctx, query, args)
+	if err != nil {
+		return ss, nil, nil, err
+	}
+	tx, err := c.client.BatchReadOnlyTransaction(ctx, staleness.bound)
+	if err != nil {
+		c.interceptors.afterQuery(ctx, ss, 0, err)
+		return ss, nil, nil, err
+	}
+	ps, err := tx.PartitionQueryWithOptions(ctx, ss, spanner.PartitionOptions{}, c.queryOptions(ctx))
+	if err != nil {
+		tx.Cleanup(ctx)
+		c.interceptors.afterQuery(ctx, ss, 0, err)
+		return ss, nil, nil, err
+	}
+	return ss, tx, ps, nil
+}
+
+// runPartitionedQuery partitions the query and executes the
+// partitions one after another.
+func (c *conn) runPartitionedQuery(ctx context.Context, query string, args []driver.NamedValue) (*rows, error) {
+	if query == "" {
+		return nil, errors.New("missing query in RUN PARTITIONED QUERY")
+	}
+	ss, tx, ps, err := c.partitionQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	r := &rows{it: &partitionIterator{
+		ctx:        ctx,
+		tx:         tx,
+		partitions: ps,
+		cleanup:    true,
+	}}
+	return c.interceptRows(ctx, ss, r, nil)
+}
+
+// runPartition executes a partition serialized by PartitionQuery.
+// The partition is given as a quoted string. The query hooks are
+// called with the partitioned query, which they can't modify.
+func (c *conn) runPartition(ctx context.Context, arg string) (*rows, error) {
+	if err := c.errIfReplaying("partitions"); err != nil {
+		return nil, err
+	}
+	if c.inTransaction() {
+		return nil, errors.New("cannot partition a query in a transaction")
+	}
+	token := strings.Trim(arg, `'"`)
+	tid, p, err := decodePartition(token)
+	if err != nil {
+		return nil, err
+	}
+	ss, err := partitionStatement(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.interceptors.beforeQuery(ctx, &ss); err != nil {
+		return nil, err
+	}
+	r := &rows{it: c.client.BatchReadOnlyTransactionFromID(tid).Execute(ctx, p)}
+	return c.interceptRows(ctx, ss, r, nil)
+}
+
+// partitionIterator iterates over the results of
+// partitions executed one after another.
+type partitionIterator struct {
+	ctx        context.Context
+	tx         *spanner.BatchReadOnlyTransaction
+	partitions []*spanner.Partition
+	cleanup    bool // whether to clean up tx when stopped
+
+	it *spanner.RowIterator
+}
+
+func (pi *partitionIterator) Next() (*spanner.Row, error) {
+	for {
+		if pi.it == nil {
+			if len(pi.partitions) == 0 {
+				return nil, iterator.Done
+			}
+			pi.it = pi.tx.Execute(pi.ctx, pi.partitions[0])
+			pi.partitions = pi.partitions[1:]
+		}
+		row, err := pi.it.Next()
+		if err == iterator.Done {
+			pi.it.Stop()
+			pi.it = nil
+			continue
+		}
+		return row, err
+	}
+}
+
+func (pi *partitionIterator) Stop() {
+	if pi.it != nil {
+		pi.it.Stop()
+		pi.it = nil
+	}
+	if pi.cleanup {
+		// The query context might be already canceled,
+		// the session needs to be deleted regardless.
+		pi.tx.Cleanup(context.Background())
+	}
+}
+
+// partitionToken is the serialized form of a partition.
+type partitionToken struct {
+	Tx        []byte
+	Partition []byte
+}
+
+func encodePartition(tid spanner.BatchReadOnlyTransactionID, p *spanner.Partition) (string, error) {
+	var token partitionToken
+	var err error
+	if token.Tx, err = tid.MarshalBinary(); err != nil {
+		return "", err
+	}
+	if token.Partition, err = p.MarshalBinary(); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodePartition(s string) (spanner.BatchReadOnlyTransactionID, *spanner.Partition, error) {
+	var tid spanner.BatchReadOnlyTransactionID
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return tid, nil, fmt.Errorf("invalid partition: %v", err)
+	}
+	var token partitionToken
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&token); err != nil {
+		return tid, nil, fmt.Errorf("invalid partition: %v", err)
+	}
+	if err := tid.UnmarshalBinary(token.Tx); err != nil {
+		return tid, nil, fmt.Errorf("invalid partition: %v", err)
+	}
+	p := &spanner.Partition{}
+	if err := p.UnmarshalBinary(token.Partition); err != nil {
+		return tid, nil, fmt.Errorf("invalid partition: %v", err)
+	}
+	return tid, p, nil
+}
+
+// partitionStatement returns the query of a partition. The
+// query is only part of the serialized partition, which is
+// gob-encoded by the Spanner client as the partition token,
+// whether it's a read partition, and the request proto.
+func partitionStatement(p *spanner.Partition) (spanner.Statement, error) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	var (
+		pt, reqData     []byte
+		isReadPartition bool
+	)
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&pt); err != nil {
+		return spanner.Statement{}, fmt.Errorf("invalid partition: %v", err)
+	}
+	if err := dec.Decode(&isReadPartition); err != nil {
+		return spanner.Statement{}, fmt.Errorf("invalid partition: %v", err)
+	}
+	if isReadPartition {
+		return spanner.Statement{}, errors.New("invalid partition: not a query partition")
+	}
+	if err := dec.Decode(&reqData); err != nil {
+		return spanner.Statement{}, fmt.Errorf("invalid partition: %v", err)
+	}
+	var req sppb.ExecuteSqlRequest
+	if err := proto.Unmarshal(reqData, &req); err != nil {
+		return spanner.Statement{}, fmt.Errorf("invalid partition: %v", err)
+	}
+	ss := spanner.Statement{SQL: req.Sql}
+	if fields := req.GetParams().GetFields(); len(fields) > 0 {
+		ss.Params = make(map[string]interface{}, len(fields))
+		for name, v := range fields {
+			ss.Params[name] = spanner.GenericColumnValue{Type: req.ParamTypes[name], Value: v}
+		}
+	}
+	return ss, nil
+}
+
+// namedValues converts the arguments given to the
+// driver's Go APIs to named values.
+func namedValues(args []interface{}) []driver.NamedValue {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if na, ok := arg.(sql.NamedArg); ok {
+			nvs[i].Name = na.Name
+			nvs[i].Value = na.Value
+		}
+	}
+	return nvs
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+const selectTexts = "SELECT text FROM tweets"
+
+// newPartitionConn returns a connection to a fake
+// server that has a result for selectTexts.
+func newPartitionConn(t *testing.T) (*fakespanner.Server, *sql.DB, *sql.Conn) {
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, selectTexts, &fakespanner.StatementResult{
+		Columns: []string{"text"},
+		Rows:    [][]interface{}{{"hello"}},
+	})
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return s, db, conn
+}
+
+func partitionQuery(ctx context.Context, conn *sql.Conn, query string) (*spannerdriver.PartitionedQuery, error) {
+	var pq *spannerdriver.PartitionedQuery
+	err := conn.Raw(func(driverConn interface{}) error {
+		var err error
+		pq, err = driverConn.(spannerdriver.Conn).PartitionQuery(ctx, query)
+		return err
+	})
+	return pq, err
+}
+
+func TestPartitionEncoding(t *testing.T) {
+	ctx := context.Background()
+	_, _, conn := newPartitionConn(t)
+	pq, err := partitionQuery(ctx, conn, selectTexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close(ctx)
+
+	for _, token := range pq.Partitions {
+		tid, p, err := spannerdriver.DecodePartition(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := spannerdriver.EncodePartition(tid, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != token {
+			t.Errorf("round trip: want: %q, got: %q", token, got)
+		}
+	}
+
+	for _, token := range []string{
+		"",
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not gob")),
+		pq.Partitions[0][:len(pq.Partitions[0])/2],
+	} {
+		if _, _, err := spannerdriver.DecodePartition(token); err == nil {
+			t.Errorf("DecodePartition(%q): want error", token)
+		}
+	}
+}
+
+func TestPartitionMalformedToken(t *testing.T) {
+	ctx := context.Background()
+	s, db, _ := newPartitionConn(t)
+
+	if _, err := spannerdriver.ExecutePartition(ctx, db, "garbage"); err == nil {
+		t.Error("RUN PARTITION with a malformed token: want error")
+	}
+	for _, req := range s.Requests() {
+		if _, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			t.Errorf("a malformed partition was executed: %v", req)
+		}
+	}
+}
+
+func TestPartitionStaleness(t *testing.T) {
+	ctx := context.Background()
+	s, _, conn := newPartitionConn(t)
+
+	if _, err := conn.ExecContext(ctx, "SET READ_ONLY_STALENESS = 'EXACT_STALENESS 10s'"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.QueryContext(ctx, "RUN PARTITIONED QUERY "+selectTexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	var begin *sppb.BeginTransactionRequest
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.BeginTransactionRequest); ok {
+			begin = req
+		}
+	}
+	if got := begin.GetOptions().GetReadOnly().GetExactStaleness().AsDuration(); got != 10*time.Second {
+		t.Errorf("exact staleness: want: 10s, got: %v (%v)", got, begin)
+	}
+
+	// Bounded staleness only applies to single reads.
+	if _, err := conn.ExecContext(ctx, "SET READ_ONLY_STALENESS = 'MAX_STALENESS 10s'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partitionQuery(ctx, conn, selectTexts); err == nil {
+		t.Error("PartitionQuery with MAX_STALENESS: want error")
+	}
+}
+
+func TestPartitionInTransaction(t *testing.T) {
+	ctx := context.Background()
+	s, _, conn := newPartitionConn(t)
+	pq, err := partitionQuery(ctx, conn, selectTexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close(ctx)
+	partitioned := len(s.Requests())
+
+	for _, opts := range []*sql.TxOptions{nil, {ReadOnly: true}} {
+		tx, err := conn.BeginTx(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.QueryContext(ctx, "RUN PARTITIONED QUERY "+selectTexts); err == nil {
+			t.Errorf("RUN PARTITIONED QUERY in a transaction (%+v): want error", opts)
+		}
+		if _, err := partitionQuery(ctx, conn, selectTexts); err == nil {
+			t.Errorf("PartitionQuery in a transaction (%+v): want error", opts)
+		}
+		if _, err := spannerdriver.ExecutePartition(ctx, tx, pq.Partitions[0]); err == nil {
+			t.Errorf("RUN PARTITION in a transaction (%+v): want error", opts)
+		}
+		if err := tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, req := range s.Requests()[partitioned:] {
+		switch req.(type) {
+		case *sppb.PartitionQueryRequest:
+			t.Errorf("a query was partitioned in a transaction: %v", req)
+		case *sppb.ExecuteSqlRequest:
+			t.Errorf("a partition was executed in a transaction: %v", req)
+		}
+	}
+}
+
+func TestPartitionInterceptors(t *testing.T) {
+	ctx := context.Background()
+	var calls []string
+	var deny bool
+	d := &spannerdriver.Driver{Interceptors: []spannerdriver.Interceptor{{
+		BeforeQuery: func(ctx context.Context, stmt *spanner.Statement) error {
+			if deny {
+				return errors.New("denied")
+			}
+			calls = append(calls, "before "+stmt.SQL)
+			return nil
+		},
+		AfterQuery: func(ctx context.Context, stmt spanner.Statement, rowsReturned int64, err error) {
+			calls = append(calls, fmt.Sprintf("after %s: %d rows, %v", stmt.SQL, rowsReturned, err))
+		},
+	}}}
+	s, db := fakespanner.NewTestDB(t, d)
+	s.MustPutStatementResult(t, selectTexts, &fakespanner.StatementResult{
+		Columns: []string{"text"},
+		Rows:    [][]interface{}{{"hello"}},
+	})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	pq, err := partitionQuery(ctx, conn, selectTexts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pq.Close(ctx)
+	rows, err := spannerdriver.ExecutePartition(ctx, conn, pq.Partitions[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	want := []string{
+		"before " + selectTexts,
+		"after " + selectTexts + ": 0 rows, <nil>",
+		"before " + selectTexts,
+		"after " + selectTexts + ": 1 rows, <nil>",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls: want: %q, got: %q", want, calls)
+	}
+
+	// A BeforeQuery hook can stop the partition from running.
+	executed := len(s.Requests())
+	deny = true
+	if _, err := spannerdriver.ExecutePartition(ctx, db, pq.Partitions[0]); err == nil || err.Error() != "denied" {
+		t.Errorf("RUN PARTITION stopped by an interceptor: want: denied, got: %v", err)
+	}
+	for _, req := range s.Requests()[executed:] {
+		if _, ok := req.(*sppb.ExecuteSqlRequest); ok {
+			t.Errorf("a partition stopped by an interceptor was executed: %v", req)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// Priority is the priority of the RPCs of statements and
+// transactions. Spanner uses it to schedule work when the
+// instance is busy.
+type Priority int
+
+const (
+	// PriorityUnspecified leaves the priority to Spanner,
+	// which executes the RPC with high priority.
+	PriorityUnspecified Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityUnspecified:
+		return "UNSPECIFIED"
+	case PriorityLow:
+		return "LOW"
+	case PriorityMedium:
+		return "MEDIUM"
+	case PriorityHigh:
+		return "HIGH"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+func (p Priority) proto() sppb.RequestOptions_Priority {
+	switch p {
+	case PriorityLow:
+		return sppb.RequestOptions_PRIORITY_LOW
+	case PriorityMedium:
+		return sppb.RequestOptions_PRIORITY_MEDIUM
+	case PriorityHigh:
+		return sppb.RequestOptions_PRIORITY_HIGH
+	}
+	return sppb.RequestOptions_PRIORITY_UNSPECIFIED
+}
+
+func parsePriority(s string) (Priority, error) {
+	switch strings.ToUpper(s) {
+	case "UNSPECIFIED", "NULL":
+		return PriorityUnspecified, nil
+	case "LOW":
+		return PriorityLow, nil
+	case "MEDIUM":
+		return PriorityMedium, nil
+	case "HIGH":
+		return PriorityHigh, nil
+	}
+	return 0, fmt.Errorf("invalid priority %q, want LOW, MEDIUM, HIGH or UNSPECIFIED", s)
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context that sets the priority of the
+// statements executed with it. If the context is passed to BeginTx,
+// the priority applies to the commit of the transaction and is the
+// default of the statements executed in the transaction.
+//
+// The priority overrides the RPC_PRIORITY connection variable.
+//
+// Example:
+//
+//	ctx = spannerdriver.WithPriority(ctx, spannerdriver.PriorityLow)
+//	res, err := db.ExecContext(ctx, "DELETE FROM tweets WHERE created < @cutoff", cutoff)
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// priority returns the priority of a statement or
+// transaction started with ctx.
+func (c *conn) priority(ctx context.Context) Priority {
+	if p := priorityFromContext(ctx); p != PriorityUnspecified {
+		return p
+	}
+	if c.txPriority != PriorityUnspecified {
+		return c.txPriority
+	}
+	return c.vars.rpcPriority
+}
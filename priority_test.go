@@ -0,0 +1,52 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"testing"
+
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+func TestPriority(t *testing.T) {
+	ctx := context.Background()
+	c := &conn{defaults: defaultConnOptions(), vars: defaultConnOptions()}
+
+	if got := c.queryOptions(ctx).Priority; got != sppb.RequestOptions_PRIORITY_UNSPECIFIED {
+		t.Errorf("default priority: want unspecified, got: %v", got)
+	}
+	if _, err := c.ExecContext(ctx, "SET RPC_PRIORITY = 'LOW'", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.queryOptions(ctx).Priority; got != sppb.RequestOptions_PRIORITY_LOW {
+		t.Errorf("connection priority: want LOW, got: %v", got)
+	}
+
+	// The priority of a transaction overrides the connection variable
+	// and the priority of a statement overrides the transaction.
+	c.txPriority = PriorityMedium
+	if got := c.transactionOptions(ctx).CommitPriority; got != sppb.RequestOptions_PRIORITY_MEDIUM {
+		t.Errorf("transaction priority: want MEDIUM, got: %v", got)
+	}
+	stmtCtx := WithPriority(ctx, PriorityHigh)
+	if got := c.queryOptions(stmtCtx).Priority; got != sppb.RequestOptions_PRIORITY_HIGH {
+		t.Errorf("statement priority: want HIGH, got: %v", got)
+	}
+
+	if _, err := c.ExecContext(ctx, "SET RPC_PRIORITY = 'URGENT'", nil); err == nil {
+		t.Errorf("invalid priority: want error")
+	}
+}
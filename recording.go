@@ -0,0 +1,467 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sync"
+
+	"cloud.google.com/go/spanner"
+	"github.com/rakyll/go-sql-driver-spanner/internal"
+	"google.golang.org/api/iterator"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+)
+
+// RecordingMode is the mode of a Recording.
+type RecordingMode int
+
+const (
+	// Record executes the statements on Spanner and writes
+	// them with their results to the file of the recording.
+	Record RecordingMode = iota + 1
+
+	// Replay serves the results of the statements from the
+	// file of the recording without connecting to Spanner.
+	Replay
+)
+
+func (m RecordingMode) String() string {
+	switch m {
+	case Record:
+		return "RECORD"
+	case Replay:
+		return "REPLAY"
+	}
+	return fmt.Sprintf("RecordingMode(%d)", int(m))
+}
+
+// Recording records the statements executed with the driver and
+// their results, or errors, to a golden file and replays them
+// to run tests deterministically and offline.
+//
+// A replayed statement is matched by its SQL and its parameters.
+// If the same statement is recorded more than once, its results
+// are replayed in the order they were recorded.
+//
+// Client statements, such as SET or START BATCH DML, are executed
+// by the driver in both modes and are not recorded. Transactions
+// are not recorded either: in replay mode, beginning, committing
+// and rolling back transactions always succeed. Mutations, DML
+// batches, partitioned queries and EXPLAIN statements cannot be
+// replayed, they fail in replay mode.
+//
+// Interceptors are called when statements are sent to Spanner,
+// they are not called for replayed statements.
+type Recording struct {
+	Mode RecordingMode
+
+	// File is the path of the golden file. In record mode,
+	// it is overwritten as the statements are executed.
+	File string
+}
+
+// recordedStatement is the result of a statement in a golden file.
+type recordedStatement struct {
+	// Method is the method the statement was executed
+	// with, either ExecContext or QueryContext.
+	Method string            `json:"method"`
+	SQL    string            `json:"sql"`
+	Params map[string]string `json:"params,omitempty"`
+
+	Columns []recordedColumn    `json:"columns,omitempty"`
+	Rows    [][]json.RawMessage `json:"rows,omitempty"`
+
+	// RowsAffected is the number of rows affected by a DML
+	// statement, including a DML statement with THEN RETURN.
+	RowsAffected int64 `json:"rows_affected,omitempty"`
+
+	// Code is the code of the error of a Spanner
+	// error, whose description is Error.
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+
+	replayed bool
+}
+
+type recordedColumn struct {
+	Name string          `json:"name"`
+	Type json.RawMessage `json:"type"`
+}
+
+// recorder records or replays the statements of the connections
+// of a connector.
+type recorder struct {
+	mode RecordingMode
+	file string
+
+	mu    sync.Mutex
+	stmts []*recordedStatement
+}
+
+// newRecorder returns the recorder of r, which can be nil. In
+// replay mode, it reads the golden file.
+func newRecorder(r *Recording) (*recorder, error) {
+	if r == nil {
+		return nil, nil
+	}
+	rec := &recorder{mode: r.Mode, file: r.File}
+	switch r.Mode {
+	case Record:
+	case Replay:
+		b, err := ioutil.ReadFile(r.File)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &rec.stmts); err != nil {
+			return nil, fmt.Errorf("invalid recording %s: %v", r.File, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid recording mode: %v", r.Mode)
+	}
+	return rec, nil
+}
+
+func (rec *recorder) replaying() bool {
+	return rec != nil && rec.mode == Replay
+}
+
+// errIfReplaying returns an error if the connection replays
+// statements. The operations that need Spanner but are neither
+// recorded nor replayed call it, as there is no Spanner client
+// in replay mode.
+func (c *conn) errIfReplaying(what string) error {
+	if c.rec.replaying() {
+		return fmt.Errorf("%s cannot be replayed", what)
+	}
+	return nil
+}
+
+// passThrough reports whether the statement is
+// neither recorded nor replayed.
+func (rec *recorder) passThrough(query string) bool {
+	if rec == nil {
+		return true
+	}
+	p, err := parseStatement(query)
+	return err != nil || p.client != nil
+}
+
+// exec records or replays the result of fn, which
+// executes query with args.
+func (rec *recorder) exec(query string, args []driver.NamedValue, fn func() (driver.Result, error)) (driver.Result, error) {
+	if rec.passThrough(query) {
+		return fn()
+	}
+	if rec.mode == Replay {
+		s, err := rec.replay("ExecContext", query, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.err(); err != nil {
+			return nil, err
+		}
+		return &result{rowsAffected: s.RowsAffected}, nil
+	}
+	s := newRecordedStatement("ExecContext", query, args)
+	res, err := fn()
+	if err != nil {
+		s.setErr(err)
+		if err := rec.add(s); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		s.RowsAffected = n
+	}
+	if err := rec.add(s); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// query records or replays the rows returned by fn,
+// which runs query with args. The statistics of the
+// replayed rows are reported like the ones of the rows
+// returned by fn, see WithResultStats.
+func (rec *recorder) query(ctx context.Context, query string, args []driver.NamedValue, fn func() (*rows, error)) (*rows, error) {
+	if rec.passThrough(query) {
+		return fn()
+	}
+	if rec.mode == Replay {
+		s, err := rec.replay("QueryContext", query, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.err(); err != nil {
+			return nil, err
+		}
+		return s.rows(resultStatsFromContext(ctx))
+	}
+	s := newRecordedStatement("QueryContext", query, args)
+	r, err := fn()
+	if err != nil {
+		s.setErr(err)
+		if err := rec.add(s); err != nil {
+			return nil, err
+		}
+		return nil, err
+	}
+	r.it = &recordingIterator{it: r.it, rec: rec, stmt: s}
+	return r, nil
+}
+
+// add records s and writes the golden file.
+func (rec *recorder) add(s *recordedStatement) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.stmts = append(rec.stmts, s)
+	b, err := json.MarshalIndent(rec.stmts, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(rec.file, append(b, '\n'), 0644)
+}
+
+// replay returns the first recorded result of query with
+// args, executed with method, that hasn't been replayed yet.
+func (rec *recorder) replay(method, query string, args []driver.NamedValue) (*recordedStatement, error) {
+	params := recordedParams(args)
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for _, s := range rec.stmts {
+		if !s.replayed && s.Method == method && s.SQL == query && reflect.DeepEqual(s.Params, params) {
+			s.replayed = true
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no recorded result for statement %q with parameters %v", query, params)
+}
+
+func newRecordedStatement(method, query string, args []driver.NamedValue) *recordedStatement {
+	return &recordedStatement{Method: method, SQL: query, Params: recordedParams(args)}
+}
+
+// recordedParams encodes the arguments of a statement by parameter
+// name. Values that Spanner can encode are recorded in the JSON
+// format of their protobuf value, e.g. "1" for an INT64.
+func recordedParams(args []driver.NamedValue) map[string]string {
+	if len(args) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(args))
+	for _, arg := range args {
+		name := arg.Name
+		if name == "" {
+			name = internal.PositionalParamName(arg.Ordinal)
+		}
+		params[name] = fmt.Sprint(arg.Value)
+		row, err := spanner.NewRow([]string{name}, []interface{}{arg.Value})
+		if err != nil {
+			continue
+		}
+		var col spanner.GenericColumnValue
+		if err := row.Column(0, &col); err != nil {
+			continue
+		}
+		if b, err := marshalProto(col.Value); err == nil {
+			params[name] = string(b)
+		}
+	}
+	return params
+}
+
+func (s *recordedStatement) setErr(err error) {
+	var se *spanner.Error
+	if errors.As(err, &se) {
+		s.Code = se.Code.String()
+		s.Error = se.Desc
+		return
+	}
+	s.Error = err.Error()
+}
+
+// err returns the recorded error, if any. Spanner
+// errors are replayed with their code.
+func (s *recordedStatement) err() error {
+	if s.Error == "" {
+		return nil
+	}
+	if s.Code == "" {
+		return errors.New(s.Error)
+	}
+	code := codes.Unknown
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == s.Code {
+			code = c
+		}
+	}
+	return spanner.ToSpannerError(status.Error(code, s.Error))
+}
+
+// addRow records a row returned by the statement.
+func (s *recordedStatement) addRow(row *spanner.Row) error {
+	var values []json.RawMessage
+	for i, name := range row.ColumnNames() {
+		var col spanner.GenericColumnValue
+		if err := row.Column(i, &col); err != nil {
+			return err
+		}
+		if len(s.Rows) == 0 {
+			t, err := marshalProto(col.Type)
+			if err != nil {
+				return err
+			}
+			s.Columns = append(s.Columns, recordedColumn{Name: name, Type: t})
+		}
+		v, err := marshalProto(col.Value)
+		if err != nil {
+			return err
+		}
+		values = append(values, v)
+	}
+	s.Rows = append(s.Rows, values)
+	return nil
+}
+
+// rows returns the recorded rows of the statement.
+func (s *recordedStatement) rows(stats *ResultStats) (*rows, error) {
+	names := make([]string, len(s.Columns))
+	types := make([]*sppb.Type, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+		types[i] = &sppb.Type{}
+		if err := protojson.Unmarshal(c.Type, types[i]); err != nil {
+			return nil, err
+		}
+	}
+	it := &bufferedIterator{RowCount: s.RowsAffected}
+	for _, values := range s.Rows {
+		if len(values) != len(names) {
+			return nil, errors.New("recorded row doesn't match the recorded columns")
+		}
+		cols := make([]interface{}, len(values))
+		for i, v := range values {
+			col := spanner.GenericColumnValue{Type: types[i], Value: &structpb.Value{}}
+			if err := protojson.Unmarshal(v, col.Value); err != nil {
+				return nil, err
+			}
+			cols[i] = col
+		}
+		row, err := spanner.NewRow(names, cols)
+		if err != nil {
+			return nil, err
+		}
+		it.rows = append(it.rows, row)
+	}
+	return &rows{it: it, stats: stats}, nil
+}
+
+// recordingIterator records the rows of a query as they are read.
+// The statement is recorded when the rows are done or stopped.
+type recordingIterator struct {
+	it   rowIterator
+	rec  *recorder
+	stmt *recordedStatement
+	done bool
+}
+
+func (it *recordingIterator) Next() (*spanner.Row, error) {
+	row, err := it.it.Next()
+	switch {
+	case err == iterator.Done:
+		it.stmt.RowsAffected = rowCount(it.it)
+		if err := it.finish(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		it.stmt.setErr(err)
+		it.finish()
+	default:
+		if err := it.stmt.addRow(row); err != nil {
+			return nil, err
+		}
+	}
+	return row, err
+}
+
+func (it *recordingIterator) Stop() {
+	it.it.Stop()
+	it.finish()
+}
+
+func (it *recordingIterator) finish() error {
+	if it.done {
+		return nil
+	}
+	it.done = true
+	return it.rec.add(it.stmt)
+}
+
+// marshalProto encodes m in compact JSON. The output of
+// protojson is unstable, the golden files need to be stable.
+func marshalProto(m proto.Message) (json.RawMessage, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// rowCount returns the number of rows modified by the
+// DML statement that returned the rows of it, if any.
+func rowCount(it rowIterator) int64 {
+	switch it := it.(type) {
+	case *spanner.RowIterator:
+		return it.RowCount
+	case *bufferedIterator:
+		return it.RowCount
+	case *recordingIterator:
+		return rowCount(it.it)
+	}
+	return 0
+}
+
+// replayTx is a transaction in replay mode. The statements
+// executed in the transaction are replayed like the others.
+type replayTx struct {
+	close func()
+}
+
+func (tx *replayTx) Commit() error {
+	tx.close()
+	return nil
+}
+
+func (tx *replayTx) Rollback() error {
+	tx.close()
+	return nil
+}
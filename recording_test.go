@@ -0,0 +1,268 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type tweet struct {
+	ID    int64
+	Text  string
+	Likes sql.NullInt64
+}
+
+// runTweets executes the statements of the recording test.
+func runTweets(ctx context.Context, db *sql.DB) ([]tweet, int64, error) {
+	if _, err := db.ExecContext(ctx, "SET STATEMENT_TAG = 'app=timeline'"); err != nil {
+		return nil, 0, err
+	}
+	res, err := db.ExecContext(ctx, "UPDATE tweets SET likes = likes + 1 WHERE id = @id", sql.Named("id", int64(1)))
+	if err != nil {
+		return nil, 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, 0, err
+	}
+	rows, err := db.QueryContext(ctx, "SELECT id, text, likes FROM tweets")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var tweets []tweet
+	for rows.Next() {
+		var t tweet
+		if err := rows.Scan(&t.ID, &t.Text, &t.Likes); err != nil {
+			return nil, 0, err
+		}
+		tweets = append(tweets, t)
+	}
+	return tweets, n, rows.Err()
+}
+
+// tempFile returns the path of a file named name in a temporary
+// directory that is removed when the test finishes.
+func tempFile(t *testing.T, name string) string {
+	dir, err := ioutil.TempDir("", "spannerdriver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, name)
+}
+
+func TestRecording(t *testing.T) {
+	ctx := context.Background()
+	file := tempFile(t, "tweets.json")
+
+	s, err := fakespanner.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MustPutStatementResult(t, "UPDATE tweets SET likes = likes + 1 WHERE id = @id", &fakespanner.StatementResult{UpdateCount: 1})
+	s.MustPutStatementResult(t, "SELECT id, text, likes FROM tweets", &fakespanner.StatementResult{
+		Columns: []string{"id", "text", "likes"},
+		Rows: [][]interface{}{
+			{int64(1), "hello", spanner.NullInt64{Int64: 1, Valid: true}},
+			{int64(2), "world", spanner.NullInt64{}},
+		},
+	})
+	s.MustPutStatementResult(t, "SELECT * FROM missing", &fakespanner.StatementResult{
+		Err: status.Error(codes.NotFound, "Table not found: missing"),
+	})
+
+	db := s.OpenDB(&spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Record, File: file},
+	})
+	recorded, recordedN, err := runTweets(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatal("ExecContext with a query: want error")
+	}
+	rows, err := db.QueryContext(ctx, "SELECT * FROM missing")
+	if err == nil {
+		for rows.Next() {
+		}
+		rows.Close()
+	}
+	db.Close()
+	s.Close()
+
+	// The server is closed, the statements are replayed.
+	connector, err := (&spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Replay, File: file},
+	}).OpenConnector(fakespanner.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = sql.OpenDB(connector)
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	replayed, replayedN, err := runTweets(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed rows: want: %v, got: %v", recorded, replayed)
+	}
+	if replayedN != recordedN {
+		t.Errorf("replayed rows affected: want: %d, got: %d", recordedN, replayedN)
+	}
+
+	rows, err = db.QueryContext(ctx, "SELECT * FROM missing")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if got := spanner.ErrCode(err); got != codes.NotFound {
+		t.Errorf("replayed error code: want: %v, got: %v (%v)", codes.NotFound, got, err)
+	}
+
+	// Each recorded result is replayed once.
+	if _, _, err := runTweets(ctx, db); err == nil {
+		t.Error("statements replayed more than recorded: want error")
+	}
+}
+
+func TestRecordingNotReplayed(t *testing.T) {
+	ctx := context.Background()
+	file := tempFile(t, "schema.json")
+	const ddl = "CREATE TABLE tweets (id INT64, text STRING(MAX)) PRIMARY KEY (id)"
+
+	_, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Record, File: file},
+	})
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	connector, err := (&spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Replay, File: file},
+	}).OpenConnector(fakespanner.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = sql.OpenDB(connector)
+	defer db.Close()
+
+	// DDL statements are replayed like DML statements.
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		t.Errorf("replayed DDL: %v", err)
+	}
+
+	// The statements that need Spanner but aren't
+	// recorded fail instead of using the missing client.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, query := range []string{
+		"EXPLAIN SELECT 1",
+		"EXPLAIN ANALYZE SELECT 1",
+		"RUN PARTITIONED QUERY SELECT 1",
+		"RUN PARTITION 'token'",
+	} {
+		rows, err := conn.QueryContext(ctx, query)
+		if err == nil {
+			rows.Close()
+			t.Errorf("%s: want error", query)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, "START BATCH DML"); err == nil {
+		t.Error("START BATCH DML: want error")
+	}
+	ms := spanner.Insert("tweets", []string{"id", "text"}, []interface{}{1, "hello"})
+	if err := spannerdriver.ApplyMutations(ctx, conn, ms); err == nil {
+		t.Error("ApplyMutations: want error")
+	}
+	err = conn.Raw(func(driverConn interface{}) error {
+		_, err := driverConn.(spannerdriver.Conn).PartitionQuery(ctx, "SELECT 1")
+		return err
+	})
+	if err == nil {
+		t.Error("PartitionQuery: want error")
+	}
+}
+
+func TestRecordingResultStats(t *testing.T) {
+	ctx := context.Background()
+	file := tempFile(t, "likes.json")
+	const query = "UPDATE tweets SET likes = likes + 1 WHERE TRUE THEN RETURN id"
+
+	s, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Record, File: file},
+	})
+	s.MustPutStatementResult(t, query, &fakespanner.StatementResult{
+		Columns:     []string{"id"},
+		Rows:        [][]interface{}{{int64(1)}, {int64(2)}},
+		UpdateCount: 2,
+	})
+	rowsAffected := func(db *sql.DB) int64 {
+		var stats spannerdriver.ResultStats
+		rows, err := db.QueryContext(spannerdriver.WithResultStats(ctx, &stats), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		return stats.RowsAffected
+	}
+	if got := rowsAffected(db); got != 2 {
+		t.Fatalf("recorded rows affected: want: 2, got: %d", got)
+	}
+	db.Close()
+
+	connector, err := (&spannerdriver.Driver{
+		Recording: &spannerdriver.Recording{Mode: spannerdriver.Replay, File: file},
+	}).OpenConnector(fakespanner.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db = sql.OpenDB(connector)
+	defer db.Close()
+	if got := rowsAffected(db); got != 2 {
+		t.Errorf("replayed rows affected: want: 2, got: %d", got)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+// ResultStats are the statistics of a statement run with QueryContext.
+// See WithResultStats.
+type ResultStats struct {
+	// RowsAffected is the number of rows modified
+	// by a DML statement with a THEN RETURN clause.
+	RowsAffected int64
+
+	// QueryPlan is the plan of the query. It is set
+	// for queries run with WithQueryProfile.
+	QueryPlan *sppb.QueryPlan
+
+	// QueryStats are the execution statistics of the query,
+	// e.g. elapsed_time and rows_scanned. They are set for
+	// queries run with WithQueryProfile.
+	QueryStats map[string]interface{}
+}
+
+type resultStatsKey struct{}
+
+// WithResultStats returns a context that makes the driver populate
+// stats with the statistics of the statement queried with the context.
+// The statistics are available once all the rows have been read.
+//
+// Example:
+//
+//	var stats spannerdriver.ResultStats
+//	rows, err := db.QueryContext(spannerdriver.WithResultStats(ctx, &stats),
+//		"UPDATE tweets SET likes = likes + 1 WHERE author = @author THEN RETURN id, likes", author)
+//	...
+//	fmt.Println(stats.RowsAffected)
+func WithResultStats(ctx context.Context, stats *ResultStats) context.Context {
+	return context.WithValue(ctx, resultStatsKey{}, stats)
+}
+
+func resultStatsFromContext(ctx context.Context) *ResultStats {
+	stats, _ := ctx.Value(resultStatsKey{}).(*ResultStats)
+	return stats
+}
+
+// queryDMLReturning runs a DML statement with a THEN RETURN clause.
+// Outside of a read-write transaction, the statement runs in a new
+// read-write transaction and the returned rows are buffered before
+// the transaction commits.
+func (c *conn) queryDMLReturning(ctx context.Context, query string, args []driver.NamedValue) (*rows, error) {
+	if c.roTx != nil {
+		return nil, errors.New("cannot write in read-only transaction")
+	}
+	if c.batch != nil {
+		return nil, errors.New("DML statements with THEN RETURN cannot be executed in a DML batch")
+	}
+	if c.rwTx == nil && c.vars.autocommitDMLMode == PartitionedNonAtomic {
+		return nil, errors.New("DML statements with THEN RETURN cannot be executed in PARTITIONED_NON_ATOMIC mode")
+	}
+	ss, err := c.prepareQuery(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if c.rwTx != nil {
+		r := &rows{it: c.rwTx.Query(ctx, ss, c.queryOptions(ctx)), stats: resultStatsFromContext(ctx)}
+		return c.interceptRows(ctx, ss, r, nil)
+	}
+
+	var buffered *bufferedIterator
+	opts := c.queryOptions(ctx)
+	fn := func(ctx context.Context, tx *spanner.ReadWriteTransaction) error {
+		it := tx.QueryWithOptions(ctx, ss, opts)
+		defer it.Stop()
+		buffered = &bufferedIterator{}
+		for {
+			row, err := it.Next()
+			if err == iterator.Done {
+				buffered.RowCount = it.RowCount
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			buffered.rows = append(buffered.rows, row)
+		}
+	}
+	if err := c.runInNewRWTransaction(ctx, fn); err != nil {
+		return c.interceptRows(ctx, ss, nil, err)
+	}
+	return c.interceptRows(ctx, ss, &rows{it: buffered, stats: resultStatsFromContext(ctx)}, nil)
+}
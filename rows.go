@@ -17,7 +17,6 @@ package spannerdriver
 import (
 	"database/sql/driver"
 	"io"
-	"log"
 	"sync"
 	"time"
 
@@ -26,8 +25,55 @@ import (
 	sppb "google.golang.org/genproto/googleapis/spanner/v1"
 )
 
+// rowIterator is implemented by *spanner.RowIterator.
+type rowIterator interface {
+	Next() (*spanner.Row, error)
+	Stop()
+}
+
+// bufferedIterator iterates over rows held in memory.
+type bufferedIterator struct {
+	rows []*spanner.Row
+
+	// RowCount is the number of rows modified by the
+	// DML statement that returned the rows, if any.
+	RowCount int64
+}
+
+func (it *bufferedIterator) Next() (*spanner.Row, error) {
+	if len(it.rows) == 0 {
+		return nil, iterator.Done
+	}
+	row := it.rows[0]
+	it.rows = it.rows[1:]
+	return row, nil
+}
+
+func (it *bufferedIterator) Stop() {
+	it.rows = nil
+}
+
 type rows struct {
-	it *spanner.RowIterator
+	it rowIterator
+
+	// stats is populated when the iteration
+	// is done, if it is set.
+	stats *ResultStats
+
+	// deadline is the statement timeout of the query, if any.
+	deadline *statementDeadline
+
+	// op is the operation of the query, if any.
+	// It ends when the rows are done or closed.
+	op      *operation
+	numRows int64
+
+	// after calls the AfterQuery hooks of the
+	// query when the rows are done or closed.
+	after func(rowsReturned int64, err error)
+
+	// faults injects the faults of the rows, if set.
+	faults *faultInjector
 
 	colsOnce sync.Once
 	cols     []string
@@ -47,14 +93,32 @@ func (r *rows) Columns() []string {
 // Close closes the rows iterator.
 func (r *rows) Close() error {
 	r.it.Stop()
+	r.deadline.done()
+	r.done(nil)
 	return nil
 }
 
+// done ends the operation of the query with the number
+// of rows that were returned and calls the AfterQuery hooks.
+func (r *rows) done(err error) {
+	if r.after != nil {
+		r.after(r.numRows, err)
+		r.after = nil
+	}
+	if r.op == nil {
+		return
+	}
+	r.op.setRowsReturned(r.numRows)
+	r.op.end(err)
+	r.op = nil
+}
+
 func (r *rows) getColumns() {
 	r.colsOnce.Do(func() {
 		row, err := r.it.Next()
 		if err != nil {
-			log.Println(err)
+			// The iterator returns the error again
+			// on the next call, in Next.
 			return
 		}
 		r.dirtyRow = row
@@ -62,6 +126,24 @@ func (r *rows) getColumns() {
 	})
 }
 
+func (r *rows) updateStats() {
+	if r.stats == nil {
+		return
+	}
+	it := r.it
+	if rec, ok := it.(*recordingIterator); ok {
+		it = rec.it
+	}
+	switch it := it.(type) {
+	case *spanner.RowIterator:
+		r.stats.RowsAffected = it.RowCount
+		r.stats.QueryPlan = it.QueryPlan
+		r.stats.QueryStats = it.QueryStats
+	case *bufferedIterator:
+		r.stats.RowsAffected = it.RowCount
+	}
+}
+
 // Next is called to populate the next row of data into
 // the provided slice. The provided slice will be the same
 // size as the Columns() are wide.
@@ -78,15 +160,24 @@ func (r *rows) Next(dest []driver.Value) error {
 		row = r.dirtyRow
 		r.dirtyRow = nil
 	} else {
+		if err := r.faults.delayRow(r.deadline); err != nil {
+			r.done(err)
+			return err
+		}
 		var err error
 		row, err = r.it.Next() // returns io.EOF when there is no next
 		if err == iterator.Done {
+			r.updateStats()
+			r.done(nil)
 			return io.EOF
 		}
 		if err != nil {
+			err = r.deadline.err(err)
+			r.done(err)
 			return err
 		}
 	}
+	r.numRows++
 
 	for i := 0; i < row.Size(); i++ {
 		var col spanner.GenericColumnValue
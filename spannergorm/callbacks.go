@@ -0,0 +1,86 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannergorm
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+)
+
+// registerCallbacks registers the callbacks that write the commit
+// timestamp to the fields with the commit_timestamp tag:
+//
+//   - Creating a model sets all of them.
+//   - Updating a model sets UpdatedAt, unless it's updated with
+//     UpdateColumn(s).
+//
+// The fields hold spannerdriver.CommitTimestamp until the model
+// is reloaded.
+func registerCallbacks(cb *gorm.Callback) {
+	cb.Create().After("gorm:update_time_stamp").Register("spanner:commit_timestamp", createCommitTimestamps)
+	cb.Update().After("gorm:update_time_stamp").Register("spanner:commit_timestamp", updateCommitTimestamps)
+}
+
+func createCommitTimestamps(scope *gorm.Scope) {
+	if scope.HasError() || scope.Dialect().GetName() != name {
+		return
+	}
+	for _, field := range scope.Fields() {
+		if isCommitTimestamp(field.StructField) {
+			scope.Err(field.Set(spannerdriver.CommitTimestamp))
+		}
+	}
+}
+
+func updateCommitTimestamps(scope *gorm.Scope) {
+	if scope.HasError() || scope.Dialect().GetName() != name {
+		return
+	}
+	if _, ok := scope.Get("gorm:update_column"); ok {
+		return
+	}
+	// Saving a model that wasn't reloaded since it was created
+	// would write a new commit timestamp to the fields that still
+	// hold the placeholder, e.g. CreatedAt. Leave them out.
+	omits := scope.OmitAttrs()
+	for _, field := range scope.Fields() {
+		if !isCommitTimestamp(field.StructField) {
+			continue
+		}
+		if field.Name == "UpdatedAt" {
+			scope.Err(scope.SetColumn(field.Name, spannerdriver.CommitTimestamp))
+		} else if isPlaceholder(field) {
+			omits = append(omits, field.Name)
+		}
+	}
+	scope.Search.Omit(omits...)
+}
+
+func isCommitTimestamp(field *gorm.StructField) bool {
+	_, ok := field.TagSettingsGet("COMMIT_TIMESTAMP")
+	return ok
+}
+
+func isPlaceholder(field *gorm.Field) bool {
+	switch v := field.Field.Interface().(type) {
+	case time.Time:
+		return v == spannerdriver.CommitTimestamp
+	case *time.Time:
+		return v != nil && *v == spannerdriver.CommitTimestamp
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spannergorm is a gorm dialect for Spanner. Importing the
+// package registers the "spanner" dialect and the driver:
+//
+//	import _ "github.com/rakyll/go-sql-driver-spanner/spannergorm"
+//
+//	db, err := gorm.Open("spanner", "projects/$PROJECT/instances/$INSTANCE/databases/$DATABASE")
+//
+// Spanner doesn't autogenerate IDs, so primary keys need to be set
+// before models are created, or have a default value. The schema of
+// the models is created with AutoMigrate instead of gorm's
+// AutoMigrate and CreateTable, which generate DDL Spanner rejects.
+package spannergorm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/rakyll/go-sql-driver-spanner" // registers the driver
+)
+
+// name is the name of the dialect and of the driver.
+const name = "spanner"
+
+func init() {
+	gorm.RegisterDialect(name, &dialect{})
+	registerCallbacks(gorm.DefaultCallback)
+}
+
+type dialect struct {
+	gorm.DefaultForeignKeyNamer
+	db gorm.SQLCommon
+}
+
+func (*dialect) GetName() string {
+	return name
+}
+
+func (d *dialect) SetDB(db gorm.SQLCommon) {
+	d.db = db
+}
+
+// BindVar returns a positional placeholder, the
+// driver binds them to the arguments in order.
+func (*dialect) BindVar(i int) string {
+	return "?"
+}
+
+func (*dialect) Quote(key string) string {
+	return "`" + key + "`"
+}
+
+// DataTypeOf returns the column type of the field. The type can be
+// set with the type tag, e.g. `gorm:"type:JSON"`. Otherwise:
+//
+//   - bool is BOOL
+//   - integers are INT64
+//   - floats are FLOAT64
+//   - strings are STRING(MAX), or STRING(n) with the size tag
+//   - []byte is BYTES(MAX), or BYTES(n) with the size tag
+//   - time.Time is TIMESTAMP
+//
+// Fields with the commit_timestamp tag are TIMESTAMP columns with
+// the allow_commit_timestamp option, and primary keys are NOT NULL.
+func (d *dialect) DataTypeOf(field *gorm.StructField) string {
+	_, notNull := field.TagSettingsGet("NOT NULL")
+	return d.columnType(field, notNull || field.IsPrimaryKey)
+}
+
+// columnType returns the column type of the field like DataTypeOf,
+// with NOT NULL if notNull is set.
+func (d *dialect) columnType(field *gorm.StructField, notNull bool) string {
+	dataValue, sqlType, size, _ := gorm.ParseFieldStructForDialect(field, d)
+	_, hasSize := field.TagSettingsGet("SIZE")
+	if sqlType == "" {
+		switch dataValue.Kind() {
+		case reflect.Bool:
+			sqlType = "BOOL"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			sqlType = "INT64"
+		case reflect.Float32, reflect.Float64:
+			sqlType = "FLOAT64"
+		case reflect.String:
+			sqlType = "STRING(" + maxSize(hasSize, size) + ")"
+		case reflect.Struct:
+			if _, ok := dataValue.Interface().(time.Time); ok {
+				sqlType = "TIMESTAMP"
+			}
+		default:
+			if gorm.IsByteArrayOrSlice(dataValue) {
+				sqlType = "BYTES(" + maxSize(hasSize, size) + ")"
+			}
+		}
+	}
+	if sqlType == "" {
+		panic(fmt.Sprintf("invalid sql type %s (%s) for spanner", dataValue.Type().Name(), dataValue.Kind().String()))
+	}
+
+	if notNull {
+		sqlType += " NOT NULL"
+	}
+	if value, ok := field.TagSettingsGet("DEFAULT"); ok {
+		sqlType += " DEFAULT (" + value + ")"
+	}
+	if isCommitTimestamp(field) {
+		sqlType += " OPTIONS (allow_commit_timestamp=true)"
+	}
+	return sqlType
+}
+
+func maxSize(hasSize bool, size int) string {
+	if !hasSize || size <= 0 {
+		return "MAX"
+	}
+	return strconv.Itoa(size)
+}
+
+func (d *dialect) HasIndex(tableName string, indexName string) bool {
+	ok, _ := d.hasIndex(tableName, indexName)
+	return ok
+}
+
+func (d *dialect) hasIndex(tableName string, indexName string) (bool, error) {
+	return d.exists("SELECT COUNT(*) FROM INFORMATION_SCHEMA.INDEXES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = ? AND INDEX_NAME = ?", tableName, indexName)
+}
+
+func (d *dialect) HasForeignKey(tableName string, foreignKeyName string) bool {
+	ok, _ := d.exists("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS WHERE TABLE_SCHEMA = '' AND TABLE_NAME = ? AND CONSTRAINT_NAME = ? AND CONSTRAINT_TYPE = 'FOREIGN KEY'", tableName, foreignKeyName)
+	return ok
+}
+
+func (d *dialect) RemoveIndex(tableName string, indexName string) error {
+	_, err := d.db.Exec("DROP INDEX " + d.Quote(indexName))
+	return err
+}
+
+func (d *dialect) HasTable(tableName string) bool {
+	ok, _ := d.hasTable(tableName)
+	return ok
+}
+
+func (d *dialect) hasTable(tableName string) (bool, error) {
+	return d.exists("SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = ?", tableName)
+}
+
+func (d *dialect) HasColumn(tableName string, columnName string) bool {
+	ok, _ := d.hasColumn(tableName, columnName)
+	return ok
+}
+
+func (d *dialect) hasColumn(tableName string, columnName string) (bool, error) {
+	return d.exists("SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = '' AND TABLE_NAME = ? AND COLUMN_NAME = ?", tableName, columnName)
+}
+
+// exists returns whether the count query returns a positive count.
+func (d *dialect) exists(query string, args ...interface{}) (bool, error) {
+	var count int64
+	if err := d.db.QueryRow(query, args...).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (d *dialect) ModifyColumn(tableName string, columnName string, typ string) error {
+	_, err := d.db.Exec(fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v %v", tableName, columnName, typ))
+	return err
+}
+
+// LimitAndOffsetSQL returns the LIMIT and OFFSET clauses. Spanner
+// doesn't accept OFFSET without LIMIT, an offset without a limit
+// comes with the largest limit.
+func (*dialect) LimitAndOffsetSQL(limit, offset interface{}) (string, error) {
+	parse := func(v interface{}) (int64, error) {
+		if v == nil {
+			return -1, nil
+		}
+		return strconv.ParseInt(fmt.Sprint(v), 0, 0)
+	}
+	l, err := parse(limit)
+	if err != nil {
+		return "", err
+	}
+	o, err := parse(offset)
+	if err != nil {
+		return "", err
+	}
+	var sql string
+	if l >= 0 {
+		sql += fmt.Sprintf(" LIMIT %d", l)
+	} else if o > 0 {
+		sql += fmt.Sprintf(" LIMIT %d", int64(math.MaxInt64))
+	}
+	if o > 0 {
+		sql += fmt.Sprintf(" OFFSET %d", o)
+	}
+	return sql, nil
+}
+
+func (*dialect) SelectFromDummyTable() string {
+	return ""
+}
+
+func (*dialect) LastInsertIDOutputInterstitial(tableName, columnName string, columns []string) string {
+	return ""
+}
+
+// LastInsertIDReturningSuffix returns a THEN RETURN clause, so that
+// gorm reads the primary key from the inserted row instead of
+// calling LastInsertId, which the driver doesn't support.
+func (*dialect) LastInsertIDReturningSuffix(tableName, columnName string) string {
+	if columnName == "*" {
+		// The model has no primary key.
+		return ""
+	}
+	return "THEN RETURN " + columnName
+}
+
+// DefaultValueStr is only used for models without values to insert,
+// Spanner rejects the statement.
+func (*dialect) DefaultValueStr() string {
+	return "DEFAULT VALUES"
+}
+
+func (*dialect) NormalizeIndexAndColumn(indexName, columnName string) (string, string) {
+	return indexName, columnName
+}
+
+// CurrentDatabase returns the default schema, the
+// schema of the tables in INFORMATION_SCHEMA.
+func (*dialect) CurrentDatabase() string {
+	return ""
+}
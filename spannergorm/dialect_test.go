@@ -0,0 +1,172 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannergorm_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	"github.com/rakyll/go-sql-driver-spanner/spannergorm"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+)
+
+type Singer struct {
+	ID        int64  `gorm:"primary_key"`
+	Name      string `gorm:"size:100;index"`
+	Email     string `gorm:"unique"`
+	Photo     []byte
+	Rating    float64
+	Active    bool
+	CreatedAt time.Time `gorm:"commit_timestamp"`
+	UpdatedAt time.Time `gorm:"commit_timestamp"`
+}
+
+type Album struct {
+	SingerID int64  `gorm:"primary_key"`
+	AlbumID  int64  `gorm:"primary_key"`
+	Title    string `gorm:"not null;unique_index:uix_albums_title"`
+	Genre    string `gorm:"not null;default:'pop'"`
+}
+
+func (Album) Interleave() spannergorm.Interleave {
+	return spannergorm.Interleave{Parent: "singers", OnDeleteCascade: true}
+}
+
+func openGormDB(t *testing.T) (*fakespanner.Server, *gorm.DB) {
+	s, sqlDB := fakespanner.NewTestDB(t, nil)
+	db, err := gorm.Open("spanner", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+func count(n int64) *fakespanner.StatementResult {
+	return &fakespanner.StatementResult{
+		Columns: []string{""},
+		Rows:    [][]interface{}{{n}},
+	}
+}
+
+func TestAutoMigrate(t *testing.T) {
+	s, db := openGormDB(t)
+	s.MustPutStatementResult(t, "SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @p1", count(0))
+	s.MustPutStatementResult(t, "SELECT COUNT(*) FROM INFORMATION_SCHEMA.INDEXES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @p1 AND INDEX_NAME = @p2", count(0))
+
+	if err := spannergorm.AutoMigrate(db, &Singer{}, &Album{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE TABLE `singers` (`id` INT64 NOT NULL, `name` STRING(100), `email` STRING(MAX), `photo` BYTES(MAX), `rating` FLOAT64, `active` BOOL, " +
+			"`created_at` TIMESTAMP OPTIONS (allow_commit_timestamp=true), `updated_at` TIMESTAMP OPTIONS (allow_commit_timestamp=true)) PRIMARY KEY (`id`)",
+		"CREATE INDEX `idx_singers_name` ON `singers` (`name`)",
+		"CREATE UNIQUE INDEX `uix_singers_email` ON `singers` (`email`)",
+		"CREATE TABLE `albums` (`singer_id` INT64 NOT NULL, `album_id` INT64 NOT NULL, `title` STRING(MAX) NOT NULL, `genre` STRING(MAX) NOT NULL DEFAULT ('pop')) " +
+			"PRIMARY KEY (`singer_id`, `album_id`), INTERLEAVE IN PARENT `singers` ON DELETE CASCADE",
+		"CREATE UNIQUE INDEX `uix_albums_title` ON `albums` (`title`)",
+	}
+	if got := ddl(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("DDL:\nwant: %q\ngot:  %q", want, got)
+	}
+
+	// Existing tables get the missing columns, but not the missing
+	// primary key columns, and only the ones with a default are NOT NULL.
+	s.ClearRequests()
+	s.MustPutStatementResult(t, "SELECT COUNT(*) FROM INFORMATION_SCHEMA.TABLES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @p1", count(1))
+	s.MustPutStatementResult(t, "SELECT COUNT(*) FROM INFORMATION_SCHEMA.INDEXES WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @p1 AND INDEX_NAME = @p2", count(1))
+	s.MustPutStatementResult(t, "SELECT COUNT(*) FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA = '' AND TABLE_NAME = @p1 AND COLUMN_NAME = @p2", count(0))
+	if err := spannergorm.AutoMigrate(db, &Album{}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{
+		"ALTER TABLE `albums` ADD COLUMN `title` STRING(MAX)",
+		"ALTER TABLE `albums` ADD COLUMN `genre` STRING(MAX) NOT NULL DEFAULT ('pop')",
+	}
+	if got := ddl(s); !reflect.DeepEqual(got, want) {
+		t.Errorf("DDL:\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func ddl(s *fakespanner.Server) []string {
+	var stmts []string
+	for _, req := range s.Requests() {
+		if req, ok := req.(*adminpb.UpdateDatabaseDdlRequest); ok {
+			stmts = append(stmts, req.Statements...)
+		}
+	}
+	return stmts
+}
+
+func TestCreate(t *testing.T) {
+	s, db := openGormDB(t)
+	s.MustPutStatementResult(t, "INSERT INTO `singers` (`id`,`name`,`email`,`photo`,`rating`,`active`,`created_at`,`updated_at`) "+
+		"VALUES (@p1,@p2,@p3,@p4,@p5,@p6,PENDING_COMMIT_TIMESTAMP(),PENDING_COMMIT_TIMESTAMP()) THEN RETURN `id`",
+		&fakespanner.StatementResult{
+			Columns: []string{"id"},
+			Rows:    [][]interface{}{{int64(7)}},
+		})
+
+	singer := &Singer{ID: 7, Name: "Marc"}
+	if err := db.Create(singer).Error; err != nil {
+		t.Fatal(err)
+	}
+	if singer.ID != 7 {
+		t.Errorf("ID: want: 7, got: %v", singer.ID)
+	}
+	var committed bool
+	for _, req := range s.Requests() {
+		if _, ok := req.(*sppb.CommitRequest); ok {
+			committed = true
+		}
+	}
+	if !committed {
+		t.Error("Create didn't commit")
+	}
+
+	// Saving the created singer doesn't overwrite its creation time.
+	s.MustPutStatementResult(t, "UPDATE `singers` SET `name` = @p1, `email` = @p2, `photo` = @p3, `rating` = @p4, `active` = @p5, "+
+		"`updated_at` = PENDING_COMMIT_TIMESTAMP()  WHERE `singers`.`id` = @p7", &fakespanner.StatementResult{UpdateCount: 1})
+	singer.Name = "Marc Richards"
+	if err := db.Save(singer).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	s, db := openGormDB(t)
+	s.MustPutStatementResult(t, "SELECT * FROM `singers`  WHERE (name = @p1) LIMIT 9223372036854775807 OFFSET 10", &fakespanner.StatementResult{
+		Columns: []string{"id", "name"},
+		Rows: [][]interface{}{
+			{int64(1), "Marc"},
+			{int64(2), "Catalina"},
+		},
+	})
+
+	var singers []Singer
+	if err := db.Where("name = ?", "Marc").Offset(10).Find(&singers).Error; err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range singers {
+		names = append(names, s.Name)
+	}
+	if want := []string{"Marc", "Catalina"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names: want: %v, got: %v", want, names)
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannergorm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Interleave is the parent table of an interleaved table.
+type Interleave struct {
+	// Parent is the name of the parent table. The primary
+	// key of the parent needs to be a prefix of the primary
+	// key of the interleaved table.
+	Parent string
+
+	// OnDeleteCascade deletes the rows of the interleaved
+	// table when their parent row is deleted.
+	OnDeleteCascade bool
+}
+
+// Interleaved is implemented by the models whose
+// table is interleaved in a parent table.
+//
+// Example:
+//
+//	type Album struct {
+//		SingerID int64 `gorm:"primary_key"`
+//		AlbumID  int64 `gorm:"primary_key"`
+//		Title    string
+//	}
+//
+//	func (Album) Interleave() spannergorm.Interleave {
+//		return spannergorm.Interleave{Parent: "singers", OnDeleteCascade: true}
+//	}
+type Interleaved interface {
+	Interleave() Interleave
+}
+
+// AutoMigrate creates the tables of the models that don't exist,
+// the missing columns of the tables that exist, and the missing
+// indexes of the index, unique_index and unique tags. Like gorm's
+// AutoMigrate, it doesn't change or drop existing columns.
+//
+// The primary key of an existing table can't change, missing
+// primary key columns aren't added. Spanner rejects NOT NULL
+// columns without a default value on tables that may have rows,
+// missing columns are only NOT NULL if they have the default tag.
+//
+// Each statement is a schema update, run with db. Models are
+// migrated in order, parents need to come before the models
+// interleaved in them. Join tables of many to many relationships
+// aren't created.
+func AutoMigrate(db *gorm.DB, values ...interface{}) error {
+	d, ok := db.Dialect().(*dialect)
+	if !ok {
+		return fmt.Errorf("spannergorm: db uses the %q dialect", db.Dialect().GetName())
+	}
+	for _, v := range values {
+		if err := autoMigrate(db, d, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func autoMigrate(db *gorm.DB, d *dialect, value interface{}) error {
+	scope := db.NewScope(value)
+	table := scope.TableName()
+	fields := columns(scope)
+
+	exists, err := d.hasTable(table)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		stmt, err := createTable(scope, fields)
+		if err != nil {
+			return err
+		}
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	} else {
+		for _, field := range fields {
+			exists, err := d.hasColumn(table, field.DBName)
+			if err != nil {
+				return err
+			}
+			if exists || field.IsPrimaryKey {
+				continue
+			}
+			_, notNull := field.TagSettingsGet("NOT NULL")
+			_, hasDefault := field.TagSettingsGet("DEFAULT")
+			typ := d.columnType(field, notNull && hasDefault)
+			stmt := fmt.Sprintf("ALTER TABLE %v ADD COLUMN %v %v", scope.QuotedTableName(), scope.Quote(field.DBName), typ)
+			if err := db.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, idx := range indexes(scope, fields) {
+		exists, err := d.hasIndex(table, idx.name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		var stmt strings.Builder
+		stmt.WriteString("CREATE ")
+		if idx.unique {
+			stmt.WriteString("UNIQUE ")
+		}
+		fmt.Fprintf(&stmt, "INDEX %v ON %v (%v)", scope.Quote(idx.name), scope.QuotedTableName(), quoteAll(scope, idx.columns))
+		if err := db.Exec(stmt.String()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// columns returns the fields of the model that are columns.
+func columns(scope *gorm.Scope) []*gorm.StructField {
+	var fields []*gorm.StructField
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsNormal && !field.IsIgnored {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// createTable returns the CREATE TABLE statement of the model.
+// Spanner takes the primary key after the column definitions.
+func createTable(scope *gorm.Scope, fields []*gorm.StructField) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New("spannergorm: model has no columns")
+	}
+	var defs, keys []string
+	for _, field := range fields {
+		defs = append(defs, scope.Quote(field.DBName)+" "+scope.Dialect().DataTypeOf(field))
+		if field.IsPrimaryKey {
+			keys = append(keys, field.DBName)
+		}
+	}
+
+	var stmt strings.Builder
+	fmt.Fprintf(&stmt, "CREATE TABLE %v (%v) PRIMARY KEY (%v)", scope.QuotedTableName(), strings.Join(defs, ", "), quoteAll(scope, keys))
+	if m, ok := scope.Value.(Interleaved); ok {
+		in := m.Interleave()
+		if in.Parent == "" {
+			return "", errors.New("spannergorm: interleaved model has no parent table")
+		}
+		fmt.Fprintf(&stmt, ", INTERLEAVE IN PARENT %v", scope.Quote(in.Parent))
+		if in.OnDeleteCascade {
+			stmt.WriteString(" ON DELETE CASCADE")
+		}
+	}
+	return stmt.String(), nil
+}
+
+type index struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+// indexes returns the indexes of the model, sorted by name. The
+// names are the ones of the tags, or generated like gorm does.
+func indexes(scope *gorm.Scope, fields []*gorm.StructField) []*index {
+	table := scope.TableName()
+	byName := make(map[string]*index)
+	add := func(name, column string, unique bool) {
+		name, column = scope.Dialect().NormalizeIndexAndColumn(name, column)
+		idx, ok := byName[name]
+		if !ok {
+			idx = &index{name: name, unique: unique}
+			byName[name] = idx
+		}
+		idx.columns = append(idx.columns, column)
+	}
+	for _, field := range fields {
+		if names, ok := field.TagSettingsGet("INDEX"); ok {
+			for _, name := range strings.Split(names, ",") {
+				if name == "INDEX" || name == "" {
+					name = scope.Dialect().BuildKeyName("idx", table, field.DBName)
+				}
+				add(name, field.DBName, false)
+			}
+		}
+		if names, ok := field.TagSettingsGet("UNIQUE_INDEX"); ok {
+			for _, name := range strings.Split(names, ",") {
+				if name == "UNIQUE_INDEX" || name == "" {
+					name = scope.Dialect().BuildKeyName("uix", table, field.DBName)
+				}
+				add(name, field.DBName, true)
+			}
+		}
+		// Spanner has no UNIQUE constraint, unique
+		// columns have a unique index instead.
+		if _, ok := field.TagSettingsGet("UNIQUE"); ok {
+			add(scope.Dialect().BuildKeyName("uix", table, field.DBName), field.DBName, true)
+		}
+	}
+
+	var idxs []*index
+	for _, idx := range byName {
+		idxs = append(idxs, idx)
+	}
+	sort.Slice(idxs, func(i, j int) bool {
+		return idxs[i].name < idxs[j].name
+	})
+	return idxs
+}
+
+func quoteAll(scope *gorm.Scope, names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = scope.Quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
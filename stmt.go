@@ -18,11 +18,28 @@ import (
 	"context"
 	"database/sql/driver"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"github.com/rakyll/go-sql-driver-spanner/internal"
 )
 
+// CommitTimestamp is a placeholder value that can be used as an
+// argument in DML statements and in mutations. Spanner replaces it
+// with the commit timestamp of the transaction. The target column
+// needs to have the allow_commit_timestamp=true option.
+//
+// Example:
+//
+//	db.ExecContext(ctx, "INSERT INTO tweets (id, text, created) VALUES (@id, @text, @created)", id, text, spannerdriver.CommitTimestamp)
+var CommitTimestamp = spanner.CommitTimestamp
+
+// pendingCommitTimestamp is the SQL function that replaces
+// the CommitTimestamp arguments in DML statements.
+const pendingCommitTimestamp = "PENDING_COMMIT_TIMESTAMP()"
+
 type stmt struct {
 	conn    *conn
 	numArgs int
@@ -50,38 +67,128 @@ func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
 }
 
 func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	ss, err := prepareSpannerStmt(s.query, args)
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+// parseParams rewrites the positional placeholders of q and
+// returns the resulting statement with the distinct names of
+// its parameters. Positional parameters are named p1 to pN,
+// other parameters are in order of their first appearance.
+func parseParams(q string) (string, []string, error) {
+	q, numPositional, err := internal.RewritePositionalParams(q)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
-
-	var it *spanner.RowIterator
-	if s.conn.roTx != nil {
-		it = s.conn.roTx.Query(ctx, ss)
-	} else if s.conn.rwTx != nil {
-		it = s.conn.rwTx.Query(ctx, ss)
-	} else {
-		it = s.conn.client.Single().Query(ctx, ss)
+	if numPositional > 0 {
+		names := make([]string, numPositional)
+		for i := range names {
+			names[i] = internal.PositionalParamName(i + 1)
+		}
+		return q, names, nil
+	}
+	names, err := internal.ParamNames(q)
+	if err != nil {
+		return "", nil, err
 	}
-	return &rows{it: it}, nil
+	return q, names, nil
 }
 
 func prepareSpannerStmt(q string, args []driver.NamedValue) (spanner.Statement, error) {
-	names, err := internal.NamedValueParamNames(q, len(args))
+	p, err := parseStatement(q)
 	if err != nil {
 		return spanner.Statement{}, err
 	}
-	ss := spanner.NewStatement(q)
-	for i, v := range args {
-		name := args[i].Name
-		if name == "" {
-			name = names[i]
+	params, err := bindParams(p.names, args)
+	if err != nil {
+		return spanner.Statement{}, err
+	}
+	ss := spanner.Statement{SQL: p.sql, Params: params}
+
+	// Spanner doesn't accept the commit timestamp placeholder
+	// as a DML parameter, inline the SQL function instead.
+	exprs := make(map[string]string)
+	for name, v := range params {
+		if isCommitTimestamp(v) {
+			exprs[name] = pendingCommitTimestamp
+			delete(params, name)
+		}
+	}
+	if len(exprs) > 0 {
+		if ss.SQL, err = internal.ReplaceNamedValueParams(p.sql, exprs); err != nil {
+			return spanner.Statement{}, err
 		}
-		ss.Params[name] = v.Value
 	}
 	return ss, nil
 }
 
+// bindParams binds the arguments to the parameters with the given
+// names:
+//
+//   - Named arguments are bound to the parameter with the same name.
+//   - The other arguments are bound, in order, to the parameters that
+//     are not bound by name.
+//
+// A parameter cannot be bound more than once, every parameter needs
+// to be bound and every named argument needs to refer to a parameter
+// of the statement.
+func bindParams(names []string, args []driver.NamedValue) (map[string]interface{}, error) {
+	params := make(map[string]interface{}, len(args))
+	var positional []driver.NamedValue
+	for _, arg := range args {
+		if arg.Name == "" {
+			positional = append(positional, arg)
+			continue
+		}
+		if _, ok := params[arg.Name]; ok {
+			return nil, fmt.Errorf("parameter @%s is bound by more than one argument", arg.Name)
+		}
+		params[arg.Name] = arg.Value
+	}
+	for _, name := range names {
+		if len(positional) == 0 {
+			break
+		}
+		if _, ok := params[name]; ok {
+			continue
+		}
+		params[name] = positional[0].Value
+		positional = positional[1:]
+	}
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("query has %d parameters but %d arguments are provided", len(names), len(args))
+	}
+
+	known := make(map[string]bool, len(names))
+	var missing, unknown []string
+	for _, name := range names {
+		known[name] = true
+		if _, ok := params[name]; !ok {
+			missing = append(missing, "@"+name)
+		}
+	}
+	for _, arg := range args {
+		if arg.Name != "" && !known[arg.Name] {
+			unknown = append(unknown, "@"+arg.Name)
+		}
+	}
+	switch {
+	case len(missing) > 0 && len(unknown) > 0:
+		return nil, fmt.Errorf("missing arguments for parameters %s and arguments for unknown parameters %s", strings.Join(missing, ", "), strings.Join(unknown, ", "))
+	case len(missing) > 0:
+		return nil, fmt.Errorf("missing arguments for parameters %s", strings.Join(missing, ", "))
+	case len(unknown) > 0:
+		return nil, fmt.Errorf("arguments for unknown parameters %s", strings.Join(unknown, ", "))
+	}
+	return params, nil
+}
+
+func isCommitTimestamp(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	// Comparing with == on purpose, the placeholder is
+	// identified by its location as well as its instant.
+	return ok && t == spanner.CommitTimestamp
+}
+
 type result struct {
 	rowsAffected int64
 }
@@ -0,0 +1,213 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestPrepareSpannerStmt(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		args    []driver.NamedValue
+		want    spanner.Statement
+		wantErr bool
+	}{
+		{
+			name:  "positional args",
+			query: "INSERT INTO tweets (id, text) VALUES (@id, @text)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: "hello"},
+			},
+			want: spanner.Statement{
+				SQL:    "INSERT INTO tweets (id, text) VALUES (@id, @text)",
+				Params: map[string]interface{}{"id": int64(1), "text": "hello"},
+			},
+		},
+		{
+			name:  "named args",
+			query: "SELECT * FROM tweets WHERE id = @id",
+			args: []driver.NamedValue{
+				{Name: "id", Ordinal: 1, Value: int64(1)},
+			},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM tweets WHERE id = @id",
+				Params: map[string]interface{}{"id": int64(1)},
+			},
+		},
+		{
+			name:  "commit timestamp",
+			query: "INSERT INTO tweets (id, created) VALUES (@id, @created)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: CommitTimestamp},
+			},
+			want: spanner.Statement{
+				SQL:    "INSERT INTO tweets (id, created) VALUES (@id, PENDING_COMMIT_TIMESTAMP())",
+				Params: map[string]interface{}{"id": int64(1)},
+			},
+		},
+		{
+			name:  "regular timestamp",
+			query: "INSERT INTO tweets (id, created) VALUES (@id, @created)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: time.Unix(0, 0).UTC()},
+			},
+			want: spanner.Statement{
+				SQL:    "INSERT INTO tweets (id, created) VALUES (@id, @created)",
+				Params: map[string]interface{}{"id": int64(1), "created": time.Unix(0, 0).UTC()},
+			},
+		},
+		{
+			name:  "question mark placeholders",
+			query: "INSERT INTO tweets (id, text) VALUES (?, ?)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: "hello"},
+			},
+			want: spanner.Statement{
+				SQL:    "INSERT INTO tweets (id, text) VALUES (@p1, @p2)",
+				Params: map[string]interface{}{"p1": int64(1), "p2": "hello"},
+			},
+		},
+		{
+			name:  "numbered placeholders",
+			query: "INSERT INTO tweets (text, id) VALUES ($2, $1)",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: "hello"},
+			},
+			want: spanner.Statement{
+				SQL:    "INSERT INTO tweets (text, id) VALUES (@p2, @p1)",
+				Params: map[string]interface{}{"p1": int64(1), "p2": "hello"},
+			},
+		},
+		{
+			name:  "repeated parameter",
+			query: "SELECT * FROM tweets WHERE a = @id OR b = @id",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+			},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM tweets WHERE a = @id OR b = @id",
+				Params: map[string]interface{}{"id": int64(1)},
+			},
+		},
+		{
+			name:  "out of order named args",
+			query: "SELECT * FROM tweets WHERE a = @a AND b = @b",
+			args: []driver.NamedValue{
+				{Name: "b", Ordinal: 1, Value: int64(2)},
+				{Name: "a", Ordinal: 2, Value: int64(1)},
+			},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM tweets WHERE a = @a AND b = @b",
+				Params: map[string]interface{}{"a": int64(1), "b": int64(2)},
+			},
+		},
+		{
+			name:  "mixed named and positional args",
+			query: "SELECT * FROM tweets WHERE a = @a AND b = @b AND c = @c",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Name: "b", Ordinal: 2, Value: int64(2)},
+				{Ordinal: 3, Value: int64(3)},
+			},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM tweets WHERE a = @a AND b = @b AND c = @c",
+				Params: map[string]interface{}{"a": int64(1), "b": int64(2), "c": int64(3)},
+			},
+		},
+		{
+			name:  "named arg for positional placeholder",
+			query: "SELECT * FROM tweets WHERE a = ? AND b = ?",
+			args: []driver.NamedValue{
+				{Name: "p1", Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: int64(2)},
+			},
+			want: spanner.Statement{
+				SQL:    "SELECT * FROM tweets WHERE a = @p1 AND b = @p2",
+				Params: map[string]interface{}{"p1": int64(1), "p2": int64(2)},
+			},
+		},
+		{
+			name:  "duplicate named args",
+			query: "SELECT * FROM tweets WHERE a = @a AND b = @b",
+			args: []driver.NamedValue{
+				{Name: "a", Ordinal: 1, Value: int64(1)},
+				{Name: "a", Ordinal: 2, Value: int64(2)},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "too many positional args with named args",
+			query: "SELECT * FROM tweets WHERE a = @a AND b = @b",
+			args: []driver.NamedValue{
+				{Name: "a", Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: int64(2)},
+				{Ordinal: 3, Value: int64(3)},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "missing args",
+			query: "SELECT * FROM tweets WHERE a = @a AND b = @b",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "unknown named args",
+			query: "SELECT * FROM tweets WHERE a = @a",
+			args: []driver.NamedValue{
+				{Name: "a", Ordinal: 1, Value: int64(1)},
+				{Name: "b", Ordinal: 2, Value: int64(2)},
+			},
+			wantErr: true,
+		},
+		{
+			name:  "too many args",
+			query: "SELECT * FROM tweets WHERE id = @id",
+			args: []driver.NamedValue{
+				{Ordinal: 1, Value: int64(1)},
+				{Ordinal: 2, Value: int64(2)},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		got, err := prepareSpannerStmt(tc.query, tc.args)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%s: want: %v, got: %v", tc.name, tc.want, got)
+		}
+	}
+}
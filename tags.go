@@ -0,0 +1,78 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner"
+)
+
+type requestTagKey struct{}
+
+type transactionTagKey struct{}
+
+// WithRequestTag returns a context that tags the statements
+// executed with it. Spanner groups query statistics by their
+// request tag. The tag overrides the STATEMENT_TAG connection
+// variable.
+//
+// Example:
+//
+//	ctx = spannerdriver.WithRequestTag(ctx, "app=timeline,action=list")
+//	rows, err := db.QueryContext(ctx, "SELECT id, text FROM tweets")
+func WithRequestTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, requestTagKey{}, tag)
+}
+
+// WithTransactionTag returns a context that tags the read-write
+// transactions started with it, either by BeginTx or by statements
+// executed outside of transactions. Spanner groups transaction
+// statistics by their transaction tag. The tag overrides the
+// TRANSACTION_TAG connection variable.
+func WithTransactionTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, transactionTagKey{}, tag)
+}
+
+func (c *conn) requestTag(ctx context.Context) string {
+	if tag, ok := ctx.Value(requestTagKey{}).(string); ok {
+		return tag
+	}
+	return c.vars.statementTag
+}
+
+func (c *conn) transactionTag(ctx context.Context) string {
+	if tag, ok := ctx.Value(transactionTagKey{}).(string); ok {
+		return tag
+	}
+	return c.vars.transactionTag
+}
+
+// queryOptions returns the options of a statement executed with ctx.
+func (c *conn) queryOptions(ctx context.Context) spanner.QueryOptions {
+	return spanner.QueryOptions{
+		Priority:   c.priority(ctx).proto(),
+		RequestTag: c.requestTag(ctx),
+	}
+}
+
+// transactionOptions returns the options of a read-write
+// transaction started with ctx.
+func (c *conn) transactionOptions(ctx context.Context) spanner.TransactionOptions {
+	return spanner.TransactionOptions{
+		CommitPriority: c.priority(ctx).proto(),
+		TransactionTag: c.transactionTag(ctx),
+	}
+}
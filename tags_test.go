@@ -0,0 +1,56 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	ctx := context.Background()
+	c := &conn{defaults: defaultConnOptions(), vars: defaultConnOptions()}
+
+	for _, q := range []string{
+		"SET STATEMENT_TAG = 'app=timeline'",
+		"SET TRANSACTION_TAG = 'job=cleanup'",
+	} {
+		if _, err := c.ExecContext(ctx, q, nil); err != nil {
+			t.Fatalf("%q: %v", q, err)
+		}
+	}
+	if got, want := c.queryOptions(ctx).RequestTag, "app=timeline"; got != want {
+		t.Errorf("request tag: want: %q, got: %q", want, got)
+	}
+	if got, want := c.transactionOptions(ctx).TransactionTag, "job=cleanup"; got != want {
+		t.Errorf("transaction tag: want: %q, got: %q", want, got)
+	}
+
+	ctx = WithRequestTag(ctx, "app=profile")
+	ctx = WithTransactionTag(ctx, "job=backfill")
+	if got, want := c.queryOptions(ctx).RequestTag, "app=profile"; got != want {
+		t.Errorf("request tag from context: want: %q, got: %q", want, got)
+	}
+	if got, want := c.transactionOptions(ctx).TransactionTag, "job=backfill"; got != want {
+		t.Errorf("transaction tag from context: want: %q, got: %q", want, got)
+	}
+
+	if err := c.ResetSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.queryOptions(context.Background()).RequestTag; got != "" {
+		t.Errorf("request tag after reset: want empty, got: %q", got)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStatementTimeout is returned if a statement or a commit
+// does not complete within the statement timeout of the connection.
+// If the context of the caller is canceled or its deadline expires
+// first, the error of the context is returned instead.
+//
+// Use errors.Is to check for ErrStatementTimeout.
+var ErrStatementTimeout = errors.New("statement timeout")
+
+// statementDeadline bounds the duration of a statement
+// by the statement timeout of the connection.
+type statementDeadline struct {
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+func newStatementDeadline(ctx context.Context, timeout time.Duration) *statementDeadline {
+	d := &statementDeadline{parent: ctx, ctx: ctx, cancel: func() {}, timeout: timeout}
+	if timeout > 0 {
+		d.ctx, d.cancel = context.WithTimeout(ctx, timeout)
+	}
+	return d
+}
+
+// err returns ErrStatementTimeout if err is caused by the
+// statement timeout rather than by the context of the caller.
+func (d *statementDeadline) err(err error) error {
+	if d == nil || err == nil || d.timeout == 0 {
+		return err
+	}
+	if d.ctx.Err() == context.DeadlineExceeded && d.parent.Err() == nil {
+		return timeoutError(d.timeout)
+	}
+	return err
+}
+
+// done releases the resources of the deadline.
+func (d *statementDeadline) done() {
+	if d != nil {
+		d.cancel()
+	}
+}
+
+func timeoutError(timeout time.Duration) error {
+	return fmt.Errorf("%w after %v", ErrStatementTimeout, timeout)
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatementDeadline(t *testing.T) {
+	errRPC := errors.New("rpc error")
+
+	d := newStatementDeadline(context.Background(), time.Millisecond)
+	<-d.ctx.Done()
+	if err := d.err(errRPC); !errors.Is(err, ErrStatementTimeout) {
+		t.Errorf("expired statement timeout: want ErrStatementTimeout, got: %v", err)
+	}
+	if err := d.err(nil); err != nil {
+		t.Errorf("no error: want nil, got: %v", err)
+	}
+	d.done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d = newStatementDeadline(ctx, time.Hour)
+	cancel()
+	if err := d.err(errRPC); err != errRPC {
+		t.Errorf("canceled by caller: want: %v, got: %v", errRPC, err)
+	}
+	d.done()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	d = newStatementDeadline(ctx, time.Hour)
+	<-d.ctx.Done()
+	if err := d.err(errRPC); err != errRPC {
+		t.Errorf("caller deadline: want: %v, got: %v", errRPC, err)
+	}
+	d.done()
+
+	d = newStatementDeadline(context.Background(), 0)
+	if _, ok := d.ctx.Deadline(); ok {
+		t.Errorf("no statement timeout: want no deadline")
+	}
+	if err := d.err(errRPC); err != errRPC {
+		t.Errorf("no statement timeout: want: %v, got: %v", errRPC, err)
+	}
+	d.done()
+}
@@ -16,62 +16,160 @@ package spannerdriver
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	"github.com/rakyll/go-sql-driver-spanner/internal"
+	"google.golang.org/grpc/codes"
 )
 
 type roTx struct {
-	close func()
+	ctx          context.Context // of BeginTx
+	inst         *instrumentation
+	interceptors interceptors
+	close        func()
 }
 
 func (tx *roTx) Commit() error {
+	_, op := tx.inst.start(tx.ctx, "Commit", transactionTypeKey.String("read_only"))
+	err := tx.interceptors.beforeCommit(tx.ctx)
 	tx.close()
-	return nil
+	if err == nil {
+		tx.interceptors.afterCommit(tx.ctx, nil)
+	}
+	op.end(err)
+	return err
 }
 
 func (tx *roTx) Rollback() error {
+	_, op := tx.inst.start(tx.ctx, "Rollback", transactionTypeKey.String("read_only"))
+	err := tx.interceptors.beforeRollback(tx.ctx)
 	tx.close()
-	return nil
+	tx.interceptors.afterRollback(tx.ctx, nil)
+	op.end(err)
+	return err
 }
 
 type rwTx struct {
-	connector *internal.RWConnector
-	close     func()
+	ctx          context.Context // of BeginTx
+	inst         *instrumentation
+	interceptors interceptors
+	faults       *faultInjector
+	connector    *internal.RWConnector
+	close        func()
+
+	// cancel cancels the context of the transaction.
+	cancel context.CancelFunc
+
+	// timeout returns the statement timeout
+	// of the connection, applied to commits.
+	timeout func() time.Duration
 }
 
-func (tx *rwTx) Query(ctx context.Context, stmt spanner.Statement) *spanner.RowIterator {
+func (tx *rwTx) Query(ctx context.Context, stmt spanner.Statement, opts spanner.QueryOptions) *spanner.RowIterator {
 	tx.connector.QueryIn <- &internal.RWQueryMessage{
 		Ctx:  ctx,
 		Stmt: stmt,
+		Opts: opts,
 	}
 	msg := <-tx.connector.QueryOut
 	return msg.It
 }
 
-func (tx *rwTx) ExecContext(ctx context.Context, stmt spanner.Statement) (int64, error) {
+func (tx *rwTx) ExecContext(ctx context.Context, stmt spanner.Statement, opts spanner.QueryOptions) (int64, error) {
 	tx.connector.ExecIn <- &internal.RWExecMessage{
 		Ctx:  ctx,
 		Stmt: stmt,
+		Opts: opts,
 	}
 	msg := <-tx.connector.ExecOut
 	return msg.Rows, msg.Error
 }
 
-func (tx *rwTx) Commit() error {
-	tx.connector.CommitIn <- struct{}{}
-	err := <-tx.connector.Errors
-	if err == nil {
-		tx.close()
+func (tx *rwTx) BatchUpdate(ctx context.Context, stmts []spanner.Statement, opts spanner.QueryOptions) ([]int64, error) {
+	tx.connector.BatchUpdateIn <- &internal.RWBatchUpdateMessage{
+		Ctx:   ctx,
+		Stmts: stmts,
+		Opts:  opts,
+	}
+	msg := <-tx.connector.BatchUpdateOut
+	return msg.Rows, msg.Error
+}
+
+func (tx *rwTx) BufferWrite(ms []*spanner.Mutation) error {
+	tx.connector.BufferWriteIn <- &internal.RWBufferWriteMessage{
+		Mutations: ms,
+	}
+	msg := <-tx.connector.BufferWriteOut
+	return msg.Error
+}
+
+func (tx *rwTx) Commit() (err error) {
+	ctx, op := tx.inst.start(tx.ctx, "Commit", transactionTypeKey.String("read_write"))
+	defer func() { op.end(err) }()
+	if err = tx.interceptors.beforeCommit(tx.ctx); err != nil {
+		tx.rollback()
+		return err
+	}
+	if tx.faults.commit() != nil {
+		// Spanner aborts the transaction instead of committing it.
+		tx.connector.RollbackIn <- struct{}{}
+		<-tx.connector.Errors
+		err = ErrAbortedTransaction
+	} else {
+		tx.connector.CommitIn <- struct{}{}
+		err = tx.waitCommit()
+	}
+	op.span.SetAttributes(attemptsKey.Int(tx.connector.Attempts))
+	tx.interceptors.afterCommit(tx.ctx, err)
+	if errors.Is(err, ErrAbortedTransaction) || spanner.ErrCode(err) == codes.Aborted {
+		tx.inst.count(ctx, MetricTransactionsAborted, 1)
 	}
+	// The transaction is over even if it failed to commit.
+	tx.close()
 	return err
 }
 
-func (tx *rwTx) Rollback() error {
+// waitCommit waits for the result of the commit. If the commit
+// exceeds the statement timeout, it is canceled. The outcome of
+// a canceled commit is unknown.
+func (tx *rwTx) waitCommit() error {
+	d := tx.timeout()
+	if d <= 0 {
+		return <-tx.connector.Errors
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case err := <-tx.connector.Errors:
+		return err
+	case <-t.C:
+		tx.cancel()
+		if err := <-tx.connector.Errors; err == nil {
+			return nil // committed before it was canceled
+		}
+		return timeoutError(d)
+	}
+}
+
+func (tx *rwTx) Rollback() (err error) {
+	_, op := tx.inst.start(tx.ctx, "Rollback", transactionTypeKey.String("read_write"))
+	defer func() { op.end(err) }()
+	hookErr := tx.interceptors.beforeRollback(tx.ctx)
+	err = tx.rollback()
+	tx.interceptors.afterRollback(tx.ctx, err)
+	if err != nil {
+		return err
+	}
+	return hookErr
+}
+
+func (tx *rwTx) rollback() error {
 	tx.connector.RollbackIn <- struct{}{}
 	err := <-tx.connector.Errors
+	tx.close()
 	if err == internal.ErrAborted {
-		tx.close()
 		return nil
 	}
 	return err
@@ -0,0 +1,198 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	spannerdriver "github.com/rakyll/go-sql-driver-spanner"
+	"github.com/rakyll/go-sql-driver-spanner/fakespanner"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const likeAll = "UPDATE tweets SET likes = likes + 1 WHERE TRUE"
+
+// executions returns the number of times sql was executed.
+func executions(s *fakespanner.Server, sql string) int {
+	var n int
+	for _, req := range s.Requests() {
+		if req, ok := req.(*sppb.ExecuteSqlRequest); ok && req.Sql == sql {
+			n++
+		}
+	}
+	return n
+}
+
+func TestTxAbortedNotRetried(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, likeAll, &fakespanner.StatementResult{UpdateCount: 1})
+
+	// Explicit transactions aren't retried, even though
+	// RETRY_ABORTS_INTERNALLY is enabled.
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, likeAll); err != nil {
+		t.Fatal(err)
+	}
+	s.PutCommitError(fakespanner.ErrAborted)
+	if err := tx.Commit(); !errors.Is(err, spannerdriver.ErrAbortedTransaction) {
+		t.Errorf("Commit: want: %v, got: %v", spannerdriver.ErrAbortedTransaction, err)
+	}
+	if n := executions(s, likeAll); n != 1 {
+		t.Errorf("executions of the aborted transaction: want: 1, got: %d", n)
+	}
+}
+
+func TestTxClosedAfterFailedCommit(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	s.MustPutStatementResult(t, likeAll, &fakespanner.StatementResult{UpdateCount: 1})
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, commitErr := range []error{
+		fakespanner.ErrAborted,
+		status.Error(codes.FailedPrecondition, "commit failed"),
+	} {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, likeAll); err != nil {
+			t.Fatal(err)
+		}
+		s.PutCommitError(commitErr)
+		if err := tx.Commit(); err == nil {
+			t.Fatalf("Commit: want error %v", commitErr)
+		}
+	}
+
+	// The connection is no longer in a transaction.
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, likeAll); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := conn.ExecContext(ctx, likeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Errorf("rows affected: want: 1, got: %d", n)
+	}
+	var rollbacks int
+	for _, req := range s.Requests() {
+		if _, ok := req.(*sppb.RollbackRequest); ok {
+			rollbacks++
+		}
+	}
+	if rollbacks == 0 {
+		t.Error("Rollback didn't roll back the transaction")
+	}
+}
+
+func TestApplyRetried(t *testing.T) {
+	ctx := context.Background()
+	s, db := fakespanner.NewTestDB(t, nil)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m := spanner.Insert("tweets", []string{"id", "text"}, []interface{}{int64(1), "hello"})
+	s.PutCommitError(fakespanner.ErrAborted)
+	if err := spannerdriver.ApplyMutations(ctx, conn, m); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(commits(s)); n != 2 {
+		t.Errorf("commits: want: 2, got: %d", n)
+	}
+	var ts time.Time
+	if err := conn.QueryRowContext(ctx, "SHOW VARIABLE COMMIT_TIMESTAMP").Scan(&ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.IsZero() {
+		t.Error("COMMIT_TIMESTAMP isn't set after ApplyMutations")
+	}
+
+	// Aborts aren't retried if RETRY_ABORTS_INTERNALLY is disabled.
+	if _, err := conn.ExecContext(ctx, "SET RETRY_ABORTS_INTERNALLY = false"); err != nil {
+		t.Fatal(err)
+	}
+	s.PutCommitError(fakespanner.ErrAborted)
+	if err := spannerdriver.ApplyMutations(ctx, conn, m); !errors.Is(err, spannerdriver.ErrAbortedTransaction) {
+		t.Errorf("ApplyMutations: want: %v, got: %v", spannerdriver.ErrAbortedTransaction, err)
+	}
+}
+
+func TestTxAttempts(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	s, db := fakespanner.NewTestDB(t, &spannerdriver.Driver{TracerProvider: tp})
+	s.MustPutStatementResult(t, likeAll, &fakespanner.StatementResult{UpdateCount: 1})
+
+	for _, commitErr := range []error{nil, fakespanner.ErrAborted} {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tx.ExecContext(ctx, likeAll); err != nil {
+			t.Fatal(err)
+		}
+		if commitErr != nil {
+			s.PutCommitError(commitErr)
+		}
+		tx.Commit()
+	}
+
+	// The aborted transaction ran a second time,
+	// which failed with ErrAbortedTransaction.
+	var attempts []int64
+	for _, span := range recorder.Ended() {
+		if span.Name() != "spanner.Commit" {
+			continue
+		}
+		for _, kv := range span.Attributes() {
+			if kv.Key == "spanner.transaction_attempts" {
+				attempts = append(attempts, kv.Value.AsInt64())
+			}
+		}
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(attempts, want) {
+		t.Errorf("attempts of the commits: want: %v, got: %v", want, attempts)
+	}
+}
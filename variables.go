@@ -0,0 +1,266 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+// connOptions are the connection variables. They can be set
+// in the data source name, with SET statements and with the
+// Go APIs of Conn.
+type connOptions struct {
+	autocommitDMLMode     AutocommitDMLMode
+	readOnlyStaleness     staleness
+	retryAbortsInternally bool
+	statementTimeout      time.Duration
+	rpcPriority           Priority
+	statementTag          string
+	transactionTag        string
+}
+
+func defaultConnOptions() connOptions {
+	return connOptions{
+		readOnlyStaleness:     strongStaleness,
+		retryAbortsInternally: true,
+	}
+}
+
+// connVar is a connection variable that can be set
+// with SET and shown with SHOW VARIABLE.
+type connVar struct {
+	// set parses the value and sets the variable.
+	// It is nil for read-only variables.
+	set func(o *connOptions, value string) error
+
+	// get returns the current value of the variable.
+	get func(c *conn) interface{}
+}
+
+var connVars = map[string]connVar{
+	"AUTOCOMMIT_DML_MODE": {
+		set: func(o *connOptions, value string) error {
+			mode, err := parseAutocommitDMLMode(value)
+			if err != nil {
+				return err
+			}
+			o.autocommitDMLMode = mode
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.autocommitDMLMode.String() },
+	},
+	"READ_ONLY_STALENESS": {
+		set: func(o *connOptions, value string) error {
+			s, err := parseStaleness(value)
+			if err != nil {
+				return err
+			}
+			o.readOnlyStaleness = s
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.readOnlyStaleness.text },
+	},
+	// RETRY_ABORTS_INTERNALLY only applies outside of explicit
+	// transactions, whose results can't be replayed.
+	"RETRY_ABORTS_INTERNALLY": {
+		set: func(o *connOptions, value string) error {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", value)
+			}
+			o.retryAbortsInternally = b
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.retryAbortsInternally },
+	},
+	"STATEMENT_TIMEOUT": {
+		set: func(o *connOptions, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid duration %q", value)
+			}
+			o.statementTimeout = d
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.statementTimeout.String() },
+	},
+	"RPC_PRIORITY": {
+		set: func(o *connOptions, value string) error {
+			p, err := parsePriority(value)
+			if err != nil {
+				return err
+			}
+			o.rpcPriority = p
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.rpcPriority.String() },
+	},
+	"STATEMENT_TAG": {
+		set: func(o *connOptions, value string) error {
+			o.statementTag = value
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.statementTag },
+	},
+	"TRANSACTION_TAG": {
+		set: func(o *connOptions, value string) error {
+			o.transactionTag = value
+			return nil
+		},
+		get: func(c *conn) interface{} { return c.vars.transactionTag },
+	},
+	"COMMIT_TIMESTAMP": {
+		get: func(c *conn) interface{} { return nullTime(c.commitTimestamp) },
+	},
+	"READ_TIMESTAMP": {
+		get: func(c *conn) interface{} { return nullTime(c.readTimestamp) },
+	},
+}
+
+// lookupConnVar finds a connection variable by its name. Names are
+// case insensitive and underscores are optional, so that both
+// READ_ONLY_STALENESS and readOnlyStaleness refer to the same variable.
+func lookupConnVar(name string) (string, connVar, bool) {
+	normalize := func(s string) string {
+		return strings.ToUpper(strings.Replace(s, "_", "", -1))
+	}
+	for n, v := range connVars {
+		if normalize(n) == normalize(name) {
+			return n, v, true
+		}
+	}
+	return "", connVar{}, false
+}
+
+func setConnVar(o *connOptions, name, value string) error {
+	n, v, ok := lookupConnVar(name)
+	if !ok {
+		return fmt.Errorf("unknown variable %q", name)
+	}
+	if v.set == nil {
+		return fmt.Errorf("variable %s is read-only", n)
+	}
+	if err := v.set(o, value); err != nil {
+		return fmt.Errorf("invalid value for %s: %v", n, err)
+	}
+	return nil
+}
+
+// execSet executes a SET statement in the form of NAME = value.
+func (c *conn) execSet(arg string) error {
+	kv := strings.SplitN(arg, "=", 2)
+	if len(kv) != 2 {
+		return errors.New("invalid SET statement, want SET NAME = value")
+	}
+	return setConnVar(&c.vars, strings.TrimSpace(kv[0]), unquote(strings.TrimSpace(kv[1])))
+}
+
+// queryShow executes a SHOW VARIABLE statement.
+func (c *conn) queryShow(name string) (*rows, error) {
+	n, v, ok := lookupConnVar(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown variable %q", name)
+	}
+	row, err := spanner.NewRow([]string{n}, []interface{}{v.get(c)})
+	if err != nil {
+		return nil, err
+	}
+	return &rows{it: &bufferedIterator{rows: []*spanner.Row{row}}}, nil
+}
+
+// ResetSession resets the connection variables to the values
+// of the data source name before the connection is reused.
+func (c *conn) ResetSession(ctx context.Context) error {
+	c.vars = c.defaults
+	c.batch = nil
+	c.commitTimestamp = time.Time{}
+	c.readTimestamp = time.Time{}
+	return nil
+}
+
+// unquote removes the quotes around a string literal.
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func nullTime(t time.Time) spanner.NullTime {
+	return spanner.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// staleness is the timestamp bound of read-only transactions
+// and single-use reads.
+type staleness struct {
+	bound spanner.TimestampBound
+	text  string
+
+	// singleUse is set if the bound is only
+	// valid for single-use reads.
+	singleUse bool
+}
+
+var strongStaleness = staleness{bound: spanner.StrongRead(), text: "STRONG"}
+
+// parseStaleness parses a timestamp bound in one of the forms:
+//
+//	STRONG
+//	MIN_READ_TIMESTAMP 2020-03-01T10:00:00Z
+//	READ_TIMESTAMP 2020-03-01T10:00:00Z
+//	MAX_STALENESS 10s
+//	EXACT_STALENESS 10s
+func parseStaleness(s string) (staleness, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return staleness{}, errors.New("empty staleness")
+	}
+	mode := strings.ToUpper(fields[0])
+	if mode == "STRONG" && len(fields) == 1 {
+		return strongStaleness, nil
+	}
+	if len(fields) != 2 {
+		return staleness{}, fmt.Errorf("invalid staleness %q", s)
+	}
+	text := mode + " " + fields[1]
+	switch mode {
+	case "MIN_READ_TIMESTAMP", "READ_TIMESTAMP":
+		t, err := time.Parse(time.RFC3339Nano, fields[1])
+		if err != nil {
+			return staleness{}, fmt.Errorf("invalid timestamp %q", fields[1])
+		}
+		if mode == "MIN_READ_TIMESTAMP" {
+			return staleness{bound: spanner.MinReadTimestamp(t), text: text, singleUse: true}, nil
+		}
+		return staleness{bound: spanner.ReadTimestamp(t), text: text}, nil
+	case "MAX_STALENESS", "EXACT_STALENESS":
+		d, err := time.ParseDuration(fields[1])
+		if err != nil || d < 0 {
+			return staleness{}, fmt.Errorf("invalid duration %q", fields[1])
+		}
+		if mode == "MAX_STALENESS" {
+			return staleness{bound: spanner.MaxStaleness(d), text: text, singleUse: true}, nil
+		}
+		return staleness{bound: spanner.ExactStaleness(d), text: text}, nil
+	}
+	return staleness{}, fmt.Errorf("invalid staleness %q", s)
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spannerdriver
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+)
+
+func TestParseStaleness(t *testing.T) {
+	ts := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		input   string
+		want    staleness
+		wantErr bool
+	}{
+		{input: "STRONG", want: strongStaleness},
+		{input: "strong", want: strongStaleness},
+		{
+			input: "MIN_READ_TIMESTAMP 2020-03-01T10:00:00Z",
+			want:  staleness{bound: spanner.MinReadTimestamp(ts), text: "MIN_READ_TIMESTAMP 2020-03-01T10:00:00Z", singleUse: true},
+		},
+		{
+			input: "read_timestamp 2020-03-01T10:00:00Z",
+			want:  staleness{bound: spanner.ReadTimestamp(ts), text: "READ_TIMESTAMP 2020-03-01T10:00:00Z"},
+		},
+		{
+			input: "MAX_STALENESS 10s",
+			want:  staleness{bound: spanner.MaxStaleness(10 * time.Second), text: "MAX_STALENESS 10s", singleUse: true},
+		},
+		{
+			input: "EXACT_STALENESS 1m",
+			want:  staleness{bound: spanner.ExactStaleness(time.Minute), text: "EXACT_STALENESS 1m"},
+		},
+		{input: "", wantErr: true},
+		{input: "STRONG 10s", wantErr: true},
+		{input: "MAX_STALENESS", wantErr: true},
+		{input: "MAX_STALENESS -10s", wantErr: true},
+		{input: "READ_TIMESTAMP yesterday", wantErr: true},
+		{input: "WEAK 10s", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := parseStaleness(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !tc.wantErr && !reflect.DeepEqual(tc.want, got) {
+			t.Errorf("%q: want: %+v, got: %+v", tc.input, tc.want, got)
+		}
+	}
+}
+
+func TestSetAndShowVariables(t *testing.T) {
+	ctx := context.Background()
+	c := &conn{defaults: defaultConnOptions(), vars: defaultConnOptions()}
+
+	show := func(name string) driver.Value {
+		t.Helper()
+		rows, err := c.QueryContext(ctx, "SHOW VARIABLE "+name, nil)
+		if err != nil {
+			t.Fatalf("SHOW VARIABLE %s: %v", name, err)
+		}
+		defer rows.Close()
+		dest := make([]driver.Value, len(rows.Columns()))
+		if err := rows.Next(dest); err != nil {
+			t.Fatalf("SHOW VARIABLE %s: %v", name, err)
+		}
+		return dest[0]
+	}
+
+	for _, q := range []string{
+		"SET READ_ONLY_STALENESS = 'MAX_STALENESS 10s'",
+		"set autocommit_dml_mode = 'partitioned_non_atomic';",
+		"SET RetryAbortsInternally = false",
+		"SET STATEMENT_TIMEOUT = '5s'",
+	} {
+		if _, err := c.ExecContext(ctx, q, nil); err != nil {
+			t.Fatalf("%q: %v", q, err)
+		}
+	}
+	if got, want := show("READ_ONLY_STALENESS"), "MAX_STALENESS 10s"; got != want {
+		t.Errorf("READ_ONLY_STALENESS: want: %v, got: %v", want, got)
+	}
+	if got, want := show("autocommit_dml_mode"), "PARTITIONED_NON_ATOMIC"; got != want {
+		t.Errorf("AUTOCOMMIT_DML_MODE: want: %v, got: %v", want, got)
+	}
+	if got, want := show("RETRY_ABORTS_INTERNALLY"), false; got != want {
+		t.Errorf("RETRY_ABORTS_INTERNALLY: want: %v, got: %v", want, got)
+	}
+	if got, want := show("STATEMENT_TIMEOUT"), "5s"; got != want {
+		t.Errorf("STATEMENT_TIMEOUT: want: %v, got: %v", want, got)
+	}
+	if got, want := c.vars.statementTimeout, 5*time.Second; got != want {
+		t.Errorf("statement timeout: want: %v, got: %v", want, got)
+	}
+
+	for _, q := range []string{
+		"SET STATEMENT_TIMEOUT = '-1s'",
+		"SET COMMIT_TIMESTAMP = '2020-03-01T10:00:00Z'",
+		"SET FOO = bar",
+		"SET READ_ONLY_STALENESS",
+		"SET READ_ONLY_STALENESS = 'MAX_STALENESS'",
+		"SHOW VARIABLE READ_ONLY_STALENESS",
+	} {
+		if _, err := c.ExecContext(ctx, q, nil); err == nil {
+			t.Errorf("%q: want error", q)
+		}
+	}
+	if _, err := c.QueryContext(ctx, "SET RETRY_ABORTS_INTERNALLY = true", nil); err == nil {
+		t.Errorf("SET with QueryContext: want error")
+	}
+
+	if err := c.ResetSession(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.vars, defaultConnOptions()) {
+		t.Errorf("after reset: want: %+v, got: %+v", defaultConnOptions(), c.vars)
+	}
+	if got := show("COMMIT_TIMESTAMP"); got != (time.Time{}) {
+		t.Errorf("COMMIT_TIMESTAMP: want zero time, got: %v", got)
+	}
+}